@@ -0,0 +1,359 @@
+// Package catalog builds an inventory of the metrics emitted by Go packages by
+// statically scanning them for `metrics.Client` calls with constant names.
+//
+//	c, err := catalog.Scan(".", "./...")
+//	if err != nil {
+//	  return err
+//	}
+//	c.WriteMarkdown(os.Stdout)
+//
+// Tag keys and sample rates are collected from `WithTags` and `WithRate`
+// calls on the same expression or local variable as the emitting call, e.g.
+// `client.WithTags(map[string]string{"status": s}).Incr("http.requests")`.
+package catalog
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/istreamlabs/go-metrics/analysis/metricslint"
+	"golang.org/x/tools/go/packages"
+)
+
+// MetricTypes maps `metrics.Client` methods to the type of metric they emit,
+// using the same names as `metrics.MetricCall.Type`.
+var MetricTypes = map[string]string{
+	"Count":        "Count",
+	"Incr":         "Count",
+	"Decr":         "Count",
+	"Gauge":        "Gauge",
+	"Timing":       "Timing",
+	"Histogram":    "Histogram",
+	"Distribution": "Distribution",
+}
+
+// Metric describes a single metric name and type found in source code.
+type Metric struct {
+	Name      string    `json:"name"`
+	Type      string    `json:"type"`
+	Methods   []string  `json:"methods"`
+	TagKeys   []string  `json:"tagKeys"`
+	Rates     []float64 `json:"rates,omitempty"`
+	Locations []string  `json:"locations"`
+}
+
+// Catalog is a list of metrics sorted by name and type.
+type Catalog struct {
+	Metrics []*Metric `json:"metrics"`
+}
+
+// Find returns the metric with the given name and type, or `nil`.
+func (c *Catalog) Find(name, metricType string) *Metric {
+	for _, m := range c.Metrics {
+		if m.Name == name && m.Type == metricType {
+			return m
+		}
+	}
+	return nil
+}
+
+// Scan loads the packages matching the patterns relative to `dir` and returns
+// a catalog of the metrics they emit. Locations are relative to `dir`.
+func Scan(dir string, patterns ...string) (*Catalog, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &packages.Config{
+		Mode: packages.NeedName | packages.NeedFiles | packages.NeedSyntax |
+			packages.NeedTypes | packages.NeedTypesInfo | packages.NeedImports,
+		Dir: dir,
+	}
+	pkgs, err := packages.Load(cfg, patterns...)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []string
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		for _, e := range pkg.Errors {
+			errs = append(errs, e.Error())
+		}
+	})
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("could not load packages:\n%s", strings.Join(errs, "\n"))
+	}
+
+	s := &scanner{dir: dir, metrics: make(map[string]*Metric)}
+	for _, pkg := range pkgs {
+		s.scan(pkg)
+	}
+
+	return s.catalog(), nil
+}
+
+// scanner collects metrics from type-checked packages.
+type scanner struct {
+	dir     string
+	metrics map[string]*Metric
+}
+
+func (s *scanner) scan(pkg *packages.Package) {
+	client := metricslint.ClientInterface(pkg.Types)
+	if client == nil {
+		return
+	}
+
+	for _, file := range pkg.Syntax {
+		assigns := assignments(pkg.TypesInfo, file)
+		ast.Inspect(file, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			method := metricslint.ClientMethod(pkg.TypesInfo, client, call)
+			if MetricTypes[method] == "" || len(call.Args) == 0 {
+				return true
+			}
+			name, ok := metricslint.ConstantString(pkg.TypesInfo, call.Args[0])
+			if !ok {
+				return true
+			}
+
+			tags, rates := modifiers(pkg.TypesInfo, client, assigns, call.Fun.(*ast.SelectorExpr).X, map[types.Object]bool{})
+			s.add(pkg.Fset.Position(call.Pos()), name, method, tags, rates)
+			return true
+		})
+	}
+}
+
+// modifiers follows a receiver expression through `WithTags` and `WithRate`
+// calls and local variables to collect tag keys and sample rates.
+func modifiers(info *types.Info, client *types.Interface, assigns map[types.Object]ast.Expr, expr ast.Expr, seen map[types.Object]bool) (tags []string, rates []float64) {
+	for {
+		switch e := ast.Unparen(expr).(type) {
+		case *ast.CallExpr:
+			switch metricslint.ClientMethod(info, client, e) {
+			case "WithTags":
+				tags = append(tags, tagKeys(info, e.Args[0])...)
+			case "WithRate":
+				// Only the rate closest to the call applies.
+				if tv, ok := info.Types[e.Args[0]]; ok && tv.Value != nil && len(rates) == 0 {
+					rate, _ := constant.Float64Val(constant.ToFloat(tv.Value))
+					rates = append(rates, rate)
+				}
+			default:
+				return
+			}
+			expr = e.Fun.(*ast.SelectorExpr).X
+		case *ast.Ident:
+			obj := info.Uses[e]
+			value, ok := assigns[obj]
+			if !ok || value == nil || seen[obj] {
+				return
+			}
+			seen[obj] = true
+			expr = value
+		default:
+			return
+		}
+	}
+}
+
+// assignments maps local variables to the single expression assigned to
+// them. Variables assigned more than once map to `nil`.
+func assignments(info *types.Info, file *ast.File) map[types.Object]ast.Expr {
+	assigns := make(map[types.Object]ast.Expr)
+	set := func(ident *ast.Ident, value ast.Expr) {
+		obj := info.Defs[ident]
+		if obj == nil {
+			obj = info.Uses[ident]
+		}
+		if obj == nil {
+			return
+		}
+		if _, ok := assigns[obj]; ok {
+			assigns[obj] = nil
+			return
+		}
+		assigns[obj] = value
+	}
+
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch t := n.(type) {
+		case *ast.AssignStmt:
+			if len(t.Lhs) != len(t.Rhs) {
+				return true
+			}
+			for i, lhs := range t.Lhs {
+				if ident, ok := lhs.(*ast.Ident); ok {
+					set(ident, t.Rhs[i])
+				}
+			}
+		case *ast.ValueSpec:
+			if len(t.Names) != len(t.Values) {
+				return true
+			}
+			for i, ident := range t.Names {
+				set(ident, t.Values[i])
+			}
+		}
+		return true
+	})
+
+	return assigns
+}
+
+// tagKeys returns the constant keys of a map literal.
+func tagKeys(info *types.Info, expr ast.Expr) []string {
+	lit, ok := ast.Unparen(expr).(*ast.CompositeLit)
+	if !ok {
+		return nil
+	}
+
+	var keys []string
+	for _, elt := range lit.Elts {
+		if kv, ok := elt.(*ast.KeyValueExpr); ok {
+			if key, ok := metricslint.ConstantString(info, kv.Key); ok {
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys
+}
+
+// add merges a single call into the catalog.
+func (s *scanner) add(pos token.Position, name, method string, tags []string, rates []float64) {
+	metricType := MetricTypes[method]
+	key := metricType + "|" + name
+
+	m, ok := s.metrics[key]
+	if !ok {
+		m = &Metric{Name: name, Type: metricType}
+		s.metrics[key] = m
+	}
+
+	filename := pos.Filename
+	if rel, err := filepath.Rel(s.dir, filename); err == nil {
+		filename = filepath.ToSlash(rel)
+	}
+
+	m.Methods = appendUnique(m.Methods, method)
+	m.TagKeys = appendUnique(m.TagKeys, tags...)
+	m.Locations = appendUnique(m.Locations, fmt.Sprintf("%s:%d", filename, pos.Line))
+	for _, rate := range rates {
+		found := false
+		for _, r := range m.Rates {
+			if r == rate {
+				found = true
+				break
+			}
+		}
+		if !found {
+			m.Rates = append(m.Rates, rate)
+		}
+	}
+}
+
+// catalog returns the collected metrics in a stable order.
+func (s *scanner) catalog() *Catalog {
+	c := &Catalog{Metrics: []*Metric{}}
+	for _, m := range s.metrics {
+		sort.Strings(m.Methods)
+		sort.Strings(m.TagKeys)
+		sort.Float64s(m.Rates)
+		sort.Strings(m.Locations)
+		if m.TagKeys == nil {
+			m.TagKeys = []string{}
+		}
+		c.Metrics = append(c.Metrics, m)
+	}
+	sortMetrics(c.Metrics)
+	return c
+}
+
+func sortMetrics(metrics []*Metric) {
+	sort.Slice(metrics, func(i, j int) bool {
+		if metrics[i].Name != metrics[j].Name {
+			return metrics[i].Name < metrics[j].Name
+		}
+		return metrics[i].Type < metrics[j].Type
+	})
+}
+
+func appendUnique(values []string, add ...string) []string {
+ADD:
+	for _, a := range add {
+		for _, v := range values {
+			if v == a {
+				continue ADD
+			}
+		}
+		values = append(values, a)
+	}
+	return values
+}
+
+// WriteJSON writes the catalog as indented JSON.
+func (c *Catalog) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(c)
+}
+
+// ReadJSON reads a catalog written by `WriteJSON`.
+func ReadJSON(r io.Reader) (*Catalog, error) {
+	var c Catalog
+	if err := json.NewDecoder(r).Decode(&c); err != nil {
+		return nil, err
+	}
+	sortMetrics(c.Metrics)
+	return &c, nil
+}
+
+// ReadJSONFile reads a catalog written by `WriteJSON` from a file.
+func ReadJSONFile(path string) (*Catalog, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadJSON(f)
+}
+
+// WriteMarkdown writes the catalog as a Markdown table.
+func (c *Catalog) WriteMarkdown(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("| Name | Type | Tags | Sample Rates | Locations |\n")
+	b.WriteString("| ---- | ---- | ---- | ------------ | --------- |\n")
+	for _, m := range c.Metrics {
+		rates := make([]string, len(m.Rates))
+		for i, r := range m.Rates {
+			rates[i] = strconv.FormatFloat(r, 'g', -1, 64)
+		}
+		fmt.Fprintf(&b, "| `%s` | %s | %s | %s | %s |\n",
+			m.Name, m.Type, codeList(m.TagKeys), strings.Join(rates, ", "), codeList(m.Locations))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// codeList formats values as comma-separated inline code.
+func codeList(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = "`" + v + "`"
+	}
+	return strings.Join(quoted, ", ")
+}
@@ -0,0 +1,100 @@
+package catalog
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestScan(t *testing.T) {
+	c, err := Scan("testdata/svc", "./...")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []*Metric{
+		{Name: "svc.latency", Type: "Timing", Methods: []string{"Timing"}, TagKeys: []string{"route"}, Rates: []float64{0.25}, Locations: []string{"handlers/handlers.go:15", "handlers/handlers.go:16"}},
+		{Name: "svc.queue.depth", Type: "Gauge", Methods: []string{"Gauge"}, TagKeys: []string{}, Locations: []string{"handlers/handlers.go:20"}},
+		{Name: "svc.requests", Type: "Count", Methods: []string{"Decr", "Incr"}, TagKeys: []string{"method", "status"}, Locations: []string{"handlers/handlers.go:12", "handlers/handlers.go:19"}},
+	}
+	if !reflect.DeepEqual(c.Metrics, expected) {
+		var b bytes.Buffer
+		c.WriteJSON(&b)
+		t.Fatalf("Unexpected catalog:\n%s", b.String())
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	c := &Catalog{Metrics: []*Metric{
+		{Name: "svc.latency", Type: "Timing", TagKeys: []string{"route"}, Rates: []float64{0.25}, Locations: []string{"a.go:1"}},
+	}}
+
+	var b bytes.Buffer
+	if err := c.WriteMarkdown(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "| Name | Type | Tags | Sample Rates | Locations |\n" +
+		"| ---- | ---- | ---- | ------------ | --------- |\n" +
+		"| `svc.latency` | Timing | `route` | 0.25 | `a.go:1` |\n"
+	if b.String() != expected {
+		t.Fatalf("Expected:\n%s\nbut got:\n%s", expected, b.String())
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	c, err := Scan("testdata/svc", "./...")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b bytes.Buffer
+	if err := c.WriteJSON(&b); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := ReadJSON(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c, loaded) {
+		t.Fatalf("Expected %+v but got %+v", c, loaded)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	old := &Catalog{Metrics: []*Metric{
+		{Name: "svc.errors", Type: "Count", TagKeys: []string{"code"}, Locations: []string{"errors.go:10"}},
+		{Name: "svc.latency", Type: "Timing", TagKeys: []string{"route", "method"}, Locations: []string{"http.go:5"}},
+		{Name: "svc.requests", Type: "Count", TagKeys: []string{"status"}, Locations: []string{"http.go:3"}},
+		{Name: "svc.workers", Type: "Gauge", TagKeys: []string{}, Locations: []string{"pool.go:8"}},
+	}}
+	new := &Catalog{Metrics: []*Metric{
+		{Name: "svc.errors", Type: "Count", TagKeys: []string{"code"}, Locations: []string{"errors.go:12"}},
+		{Name: "svc.http.requests", Type: "Count", TagKeys: []string{"status"}, Locations: []string{"http.go:3"}},
+		{Name: "svc.latency", Type: "Timing", TagKeys: []string{"route"}, Rates: []float64{0.5}, Locations: []string{"http.go:5"}},
+		{Name: "svc.queue.depth", Type: "Gauge", TagKeys: []string{}, Locations: []string{"queue.go:2"}},
+	}}
+
+	changes := Diff(old, new)
+
+	var b bytes.Buffer
+	WriteDiff(&b, changes)
+	expected := "~ svc.latency (Timing): removed tag method, sample rates [] -> [0.5]\n" +
+		"+ svc.queue.depth (Gauge)\n" +
+		"~ svc.requests -> svc.http.requests (Count)\n" +
+		"- svc.workers (Gauge)\n"
+	if b.String() != expected {
+		t.Fatalf("Expected:\n%s\nbut got:\n%s", expected, b.String())
+	}
+
+	var breaking []string
+	for _, c := range changes {
+		if c.Breaking() {
+			breaking = append(breaking, changeName(c))
+		}
+	}
+	if strings.Join(breaking, ",") != "svc.latency,svc.requests,svc.workers" {
+		t.Fatalf("Unexpected breaking changes: %v", breaking)
+	}
+}
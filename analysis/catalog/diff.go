@@ -0,0 +1,194 @@
+package catalog
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/istreamlabs/go-metrics/internal/levenshtein"
+)
+
+// Change kinds reported by `Diff`.
+const (
+	Added   = "added"
+	Removed = "removed"
+	Renamed = "renamed"
+	Changed = "changed"
+)
+
+// Change describes a difference between two catalogs. `Old` is `nil` for
+// added metrics and `New` is `nil` for removed metrics.
+type Change struct {
+	Kind    string   `json:"kind"`
+	Old     *Metric  `json:"old,omitempty"`
+	New     *Metric  `json:"new,omitempty"`
+	Details []string `json:"details,omitempty"`
+}
+
+// Breaking returns whether the change may break dashboards, monitors, or
+// other consumers of the old metric.
+func (c Change) Breaking() bool {
+	switch c.Kind {
+	case Removed, Renamed:
+		return true
+	case Changed:
+		return len(c.Old.TagKeys) > 0 && len(removedStrings(c.Old.TagKeys, c.New.TagKeys)) > 0
+	}
+	return false
+}
+
+func (c Change) String() string {
+	var s string
+	switch c.Kind {
+	case Added:
+		s = fmt.Sprintf("+ %s (%s)", c.New.Name, c.New.Type)
+	case Removed:
+		s = fmt.Sprintf("- %s (%s)", c.Old.Name, c.Old.Type)
+	case Renamed:
+		s = fmt.Sprintf("~ %s -> %s (%s)", c.Old.Name, c.New.Name, c.New.Type)
+	case Changed:
+		s = fmt.Sprintf("~ %s (%s)", c.New.Name, c.New.Type)
+	}
+	if len(c.Details) > 0 {
+		s += ": " + strings.Join(c.Details, ", ")
+	}
+	return s
+}
+
+// Diff compares two catalogs and returns the added, removed, renamed, and
+// changed metrics. A removed metric is considered renamed to an added metric
+// of the same type when their names are similar or they were emitted from
+// the same file with the same tag keys.
+func Diff(old, new *Catalog) []Change {
+	var changes []Change
+	var removed, added []*Metric
+
+	for _, o := range old.Metrics {
+		n := new.Find(o.Name, o.Type)
+		if n == nil {
+			removed = append(removed, o)
+			continue
+		}
+		if details := compare(o, n); len(details) > 0 {
+			changes = append(changes, Change{Kind: Changed, Old: o, New: n, Details: details})
+		}
+	}
+	for _, n := range new.Metrics {
+		if old.Find(n.Name, n.Type) == nil {
+			added = append(added, n)
+		}
+	}
+
+	used := make(map[*Metric]bool)
+	for _, o := range removed {
+		if n := renamed(o, added, used); n != nil {
+			used[n] = true
+			changes = append(changes, Change{Kind: Renamed, Old: o, New: n, Details: compare(o, n)})
+			continue
+		}
+		changes = append(changes, Change{Kind: Removed, Old: o})
+	}
+	for _, n := range added {
+		if !used[n] {
+			changes = append(changes, Change{Kind: Added, New: n})
+		}
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changeName(changes[i]) < changeName(changes[j])
+	})
+
+	return changes
+}
+
+// changeName returns the name used to order changes.
+func changeName(c Change) string {
+	if c.Old != nil {
+		return c.Old.Name
+	}
+	return c.New.Name
+}
+
+// renamed returns the most likely new name for a removed metric, or `nil`.
+func renamed(o *Metric, added []*Metric, used map[*Metric]bool) *Metric {
+	var best *Metric
+	bestDistance := 0
+	for _, n := range added {
+		if used[n] || n.Type != o.Type {
+			continue
+		}
+		distance := levenshtein.Distance(o.Name, n.Name)
+		similar := distance <= (len(o.Name)+2)/3
+		sameSource := sameFiles(o, n) && strings.Join(o.TagKeys, ",") == strings.Join(n.TagKeys, ",")
+		if !similar && !sameSource {
+			continue
+		}
+		if best == nil || distance < bestDistance {
+			best = n
+			bestDistance = distance
+		}
+	}
+	return best
+}
+
+// sameFiles returns whether two metrics are emitted from a common file.
+func sameFiles(a, b *Metric) bool {
+	files := make(map[string]bool)
+	for _, loc := range a.Locations {
+		files[locationFile(loc)] = true
+	}
+	for _, loc := range b.Locations {
+		if files[locationFile(loc)] {
+			return true
+		}
+	}
+	return false
+}
+
+func locationFile(loc string) string {
+	if i := strings.LastIndex(loc, ":"); i != -1 {
+		return loc[:i]
+	}
+	return loc
+}
+
+// compare describes tag key and sample rate differences between two metrics.
+func compare(o, n *Metric) []string {
+	var details []string
+	for _, key := range removedStrings(o.TagKeys, n.TagKeys) {
+		details = append(details, "removed tag "+key)
+	}
+	for _, key := range removedStrings(n.TagKeys, o.TagKeys) {
+		details = append(details, "added tag "+key)
+	}
+	if fmt.Sprint(o.Rates) != fmt.Sprint(n.Rates) {
+		details = append(details, fmt.Sprintf("sample rates %v -> %v", o.Rates, n.Rates))
+	}
+	return details
+}
+
+// removedStrings returns the values in `a` which are not in `b`.
+func removedStrings(a, b []string) []string {
+	var removed []string
+OUTER:
+	for _, v := range a {
+		for _, w := range b {
+			if v == w {
+				continue OUTER
+			}
+		}
+		removed = append(removed, v)
+	}
+	return removed
+}
+
+// WriteDiff writes a human readable list of changes.
+func WriteDiff(w io.Writer, changes []Change) error {
+	for _, c := range changes {
+		if _, err := fmt.Fprintln(w, c.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
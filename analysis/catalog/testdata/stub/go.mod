@@ -0,0 +1,3 @@
+module github.com/istreamlabs/go-metrics
+
+go 1.12
@@ -0,0 +1,33 @@
+// Package metrics is a minimal stub of the real metrics package used by the
+// analyzer tests.
+package metrics
+
+import "time"
+
+type Client interface {
+	WithTags(tags map[string]string) Client
+	WithRate(rate float64) Client
+	Count(name string, value int64)
+	Incr(name string)
+	Decr(name string)
+	Gauge(name string, value float64)
+	Timing(name string, value time.Duration)
+	Histogram(name string, value float64)
+	Distribution(name string, value float64)
+	Close() error
+}
+
+type NullClient struct{}
+
+func NewNullClient() *NullClient { return &NullClient{} }
+
+func (c *NullClient) WithTags(tags map[string]string) Client  { return c }
+func (c *NullClient) WithRate(rate float64) Client            { return c }
+func (c *NullClient) Count(name string, value int64)          {}
+func (c *NullClient) Incr(name string)                        {}
+func (c *NullClient) Decr(name string)                        {}
+func (c *NullClient) Gauge(name string, value float64)        {}
+func (c *NullClient) Timing(name string, value time.Duration) {}
+func (c *NullClient) Histogram(name string, value float64)    {}
+func (c *NullClient) Distribution(name string, value float64) {}
+func (c *NullClient) Close() error                            { return nil }
@@ -0,0 +1,7 @@
+module example.com/svc
+
+go 1.12
+
+require github.com/istreamlabs/go-metrics v1.8.0
+
+replace github.com/istreamlabs/go-metrics => ../stub
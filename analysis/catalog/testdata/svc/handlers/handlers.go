@@ -0,0 +1,21 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/istreamlabs/go-metrics/metrics"
+)
+
+const prefix = "svc."
+
+func Handle(client metrics.Client, status string, dynamic string) {
+	client.WithTags(map[string]string{"status": status, "method": "GET"}).Incr(prefix + "requests")
+
+	sampled := client.WithRate(0.25).WithTags(map[string]string{"route": "/"})
+	sampled.Timing("svc.latency", time.Second)
+	sampled.Timing("svc.latency", 2*time.Second)
+
+	client.Count(dynamic, 1)
+	client.Decr("svc.requests")
+	client.Gauge("svc.queue.depth", 1)
+}
@@ -0,0 +1,192 @@
+// Command metrics-catalog generates an inventory of the metrics emitted by Go
+// packages by statically scanning them for `metrics.Client` calls with
+// constant names. It can also compare catalogs between git revisions to catch
+// removed or renamed metrics before they break dashboards and monitors:
+//
+//	# Write a Markdown table of all metrics in the current module.
+//	metrics-catalog ./... > METRICS.md
+//
+//	# Write a JSON catalog.
+//	metrics-catalog -format json ./... > metrics.json
+//
+//	# Compare the metrics on main with the working tree.
+//	metrics-catalog diff main . ./...
+//
+// Each side of a diff is either a JSON catalog file, `.` for the working
+// tree, or a git revision which is checked out into a temporary worktree.
+// The diff command exits with status 1 if any metrics were removed, renamed,
+// or lost tags.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/istreamlabs/go-metrics/analysis/catalog"
+)
+
+// errBreaking is returned when a diff contains breaking changes.
+var errBreaking = fmt.Errorf("breaking metric changes found")
+
+func main() {
+	err := run(os.Args[1:], os.Stdout)
+	if err == errBreaking {
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+}
+
+// run executes the command with the given arguments.
+func run(args []string, out io.Writer) error {
+	if len(args) > 0 && args[0] == "diff" {
+		return runDiff(args[1:], out)
+	}
+
+	flags := flag.NewFlagSet("metrics-catalog", flag.ContinueOnError)
+	format := flags.String("format", "markdown", "output format: markdown or json")
+	dir := flags.String("dir", ".", "directory to load packages from")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	c, err := catalog.Scan(*dir, patterns(flags.Args())...)
+	if err != nil {
+		return err
+	}
+
+	switch *format {
+	case "markdown":
+		return c.WriteMarkdown(out)
+	case "json":
+		return c.WriteJSON(out)
+	}
+	return fmt.Errorf("unknown format %q", *format)
+}
+
+// runDiff compares two catalogs.
+func runDiff(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("metrics-catalog diff", flag.ContinueOnError)
+	format := flags.String("format", "text", "output format: text or json")
+	dir := flags.String("dir", ".", "directory to load packages from")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: metrics-catalog diff [flags] OLD NEW [packages]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() < 2 {
+		flags.Usage()
+		return fmt.Errorf("diff requires two catalogs or revisions")
+	}
+
+	pkgs := patterns(flags.Args()[2:])
+	old, err := load(*dir, flags.Arg(0), pkgs)
+	if err != nil {
+		return err
+	}
+	new, err := load(*dir, flags.Arg(1), pkgs)
+	if err != nil {
+		return err
+	}
+
+	changes := catalog.Diff(old, new)
+	switch *format {
+	case "text":
+		err = catalog.WriteDiff(out, changes)
+	case "json":
+		if changes == nil {
+			changes = []catalog.Change{}
+		}
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(changes)
+	default:
+		err = fmt.Errorf("unknown format %q", *format)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, c := range changes {
+		if c.Breaking() {
+			return errBreaking
+		}
+	}
+	return nil
+}
+
+// patterns defaults to all packages in the directory.
+func patterns(args []string) []string {
+	if len(args) == 0 {
+		return []string{"./..."}
+	}
+	return args
+}
+
+// load returns a catalog from a JSON file, the working tree (`.`), or a git
+// revision.
+func load(dir, ref string, pkgs []string) (*catalog.Catalog, error) {
+	if ref == "." {
+		return catalog.Scan(dir, pkgs...)
+	}
+	if info, err := os.Stat(ref); err == nil && !info.IsDir() {
+		return catalog.ReadJSONFile(ref)
+	}
+	return scanRevision(dir, ref, pkgs)
+}
+
+// scanRevision checks out a git revision into a temporary worktree and scans
+// the same directory within it.
+func scanRevision(dir, rev string, pkgs []string) (*catalog.Catalog, error) {
+	top, err := git(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if abs, err = filepath.EvalSymlinks(abs); err != nil {
+		return nil, err
+	}
+	rel, err := filepath.Rel(top, abs)
+	if err != nil {
+		return nil, err
+	}
+
+	tmp, err := ioutil.TempDir("", "metrics-catalog")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	worktree := filepath.Join(tmp, "worktree")
+	if _, err := git(dir, "worktree", "add", "--detach", worktree, rev); err != nil {
+		return nil, err
+	}
+	defer git(dir, "worktree", "remove", "--force", worktree)
+
+	return catalog.Scan(filepath.Join(worktree, rel), pkgs...)
+}
+
+// git runs a git command in a directory and returns its trimmed output.
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// copyDir copies a directory tree of small files.
+func copyDir(t *testing.T, src, dst string) {
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(src, path)
+		target := filepath.Join(dst, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(target, data, 0644)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiffRevisions(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	repo, err := ioutil.TempDir("", "metrics-catalog-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(repo)
+
+	copyDir(t, "../../catalog/testdata", repo)
+	svc := filepath.Join(repo, "svc")
+
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "-A"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "v1"},
+	} {
+		if _, err := git(repo, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Rename a metric in the working tree.
+	handlers := filepath.Join(svc, "handlers", "handlers.go")
+	data, err := ioutil.ReadFile(handlers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = bytes.Replace(data, []byte(`"svc.queue.depth"`), []byte(`"svc.queue.size"`), 1)
+	if err := ioutil.WriteFile(handlers, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	err = run([]string{"diff", "-dir", svc, "HEAD", "."}, &out)
+	if err != errBreaking {
+		t.Fatalf("Expected breaking changes but got %v", err)
+	}
+	expected := "~ svc.queue.depth -> svc.queue.size (Gauge)\n"
+	if out.String() != expected {
+		t.Fatalf("Expected %q but got %q", expected, out.String())
+	}
+
+	// Comparing a revision to itself via a saved JSON catalog has no changes.
+	out.Reset()
+	if err := run([]string{"-dir", svc, "-format", "json"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	saved := filepath.Join(repo, "metrics.json")
+	if err := ioutil.WriteFile(saved, out.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	if err := run([]string{"diff", "-dir", svc, saved, "."}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(out.String()) != "" {
+		t.Fatalf("Expected no changes but got %q", out.String())
+	}
+
+	// The temporary worktree is cleaned up.
+	worktrees, err := git(repo, "worktree", "list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(worktrees, "\n") != 0 {
+		t.Fatalf("Expected worktrees to be removed but got:\n%s", worktrees)
+	}
+}
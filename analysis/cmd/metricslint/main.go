@@ -0,0 +1,16 @@
+// Command metricslint checks calls on `metrics.Client` for metric naming
+// conventions, unbounded tags, and invalid sample rates. It can be run
+// directly or via `go vet`:
+//
+//	metricslint ./...
+//	go vet -vettool=$(which metricslint) -metricslint.prefixes=myapp. ./...
+package main
+
+import (
+	"github.com/istreamlabs/go-metrics/analysis/metricslint"
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+func main() {
+	singlechecker.Main(metricslint.Analyzer)
+}
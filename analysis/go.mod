@@ -0,0 +1,13 @@
+module github.com/istreamlabs/go-metrics/analysis
+
+go 1.26.0
+
+require (
+	github.com/istreamlabs/go-metrics v1.8.1-0.20261018134930-dddd05177d65
+	golang.org/x/tools v0.50.0
+)
+
+require (
+	golang.org/x/mod v0.41.0 // indirect
+	golang.org/x/sync v0.23.0 // indirect
+)
@@ -0,0 +1,288 @@
+// Package metricslint provides a static analyzer which checks calls on
+// `metrics.Client` for metric naming conventions and tag cardinality
+// problems. It can be run via `go vet`:
+//
+//	go install github.com/istreamlabs/go-metrics/analysis/cmd/metricslint
+//	go vet -vettool=$(which metricslint) ./...
+//
+// The following problems are reported:
+//
+//   - Constant metric names which are not lowercase and dotted, do not start
+//     with an allowed prefix, are too long, or contain characters DataDog
+//     does not support.
+//   - `WithTags` map literals with non-constant keys.
+//   - `WithTags` map literals with values that look unbounded, e.g. IDs,
+//     URLs, timestamps, or error messages, which create a new DataDog time
+//     series per unique value.
+//   - `WithRate` literals outside of (0, 1].
+package metricslint
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"regexp"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+// MetricsPath is the import path of the metrics package.
+const MetricsPath = "github.com/istreamlabs/go-metrics/metrics"
+
+// Analyzer checks metric names, tags, and sample rates.
+var Analyzer = &analysis.Analyzer{
+	Name:     "metricslint",
+	Doc:      "check metrics.Client calls for metric naming conventions and tag cardinality",
+	Run:      run,
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+}
+
+// Flags configuring the analyzer.
+var (
+	prefixes       string
+	maxLength      int
+	checkUnbounded bool
+)
+
+func init() {
+	Analyzer.Flags.StringVar(&prefixes, "prefixes", "", "comma-separated list of allowed metric name prefixes")
+	Analyzer.Flags.IntVar(&maxLength, "max-length", 200, "maximum metric name length")
+	Analyzer.Flags.BoolVar(&checkUnbounded, "unbounded-tags", true, "report tag values from likely unbounded sources")
+}
+
+// NameMethods are the `metrics.Client` methods whose first argument is a
+// metric name.
+var NameMethods = map[string]bool{
+	"Count":        true,
+	"Incr":         true,
+	"Decr":         true,
+	"Gauge":        true,
+	"Timing":       true,
+	"Histogram":    true,
+	"Distribution": true,
+}
+
+var (
+	// datadogName matches names following DataDog's rules: starting with a
+	// letter and containing only ASCII alphanumerics, underscores, and periods.
+	datadogName = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_.]*$`)
+
+	// conventionalName matches lowercase, dotted names like `http.requests`.
+	conventionalName = regexp.MustCompile(`^[a-z][a-z0-9_]*(\.[a-z0-9_]+)*$`)
+
+	// unboundedName matches identifiers which likely hold unbounded values,
+	// either as a snake_case word in any case or a camelCase suffix. Only the
+	// snake_case half is case-insensitive, otherwise the camelCase half would
+	// match ordinary words like `valid` or `zip`.
+	unboundedName = regexp.MustCompile(`(?i:(^|_)(id|uuid|guid|email|token|session|url|uri|path|ip|addr|timestamp))$|[a-z](ID|Id|UUID|Uuid|Email|Token|Session|URL|Url|URI|Uri|Path|IP|Ip|Addr|Timestamp)$`)
+)
+
+// ClientInterface returns the `metrics.Client` interface type if the package
+// being analyzed is or imports the metrics package, otherwise `nil`.
+func ClientInterface(pkg *types.Package) *types.Interface {
+	metricsPkg := findPackage(pkg, map[*types.Package]bool{})
+	if metricsPkg == nil {
+		return nil
+	}
+	obj := metricsPkg.Scope().Lookup("Client")
+	if obj == nil {
+		return nil
+	}
+	iface, _ := obj.Type().Underlying().(*types.Interface)
+	return iface
+}
+
+// findPackage searches the import graph for the metrics package.
+func findPackage(pkg *types.Package, seen map[*types.Package]bool) *types.Package {
+	if pkg.Path() == MetricsPath {
+		return pkg
+	}
+	seen[pkg] = true
+	for _, imp := range pkg.Imports() {
+		if seen[imp] {
+			continue
+		}
+		if found := findPackage(imp, seen); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// ClientMethod returns the name of the method if `call` is a method call on a
+// value implementing `metrics.Client`, otherwise an empty string.
+func ClientMethod(info *types.Info, client *types.Interface, call *ast.CallExpr) string {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return ""
+	}
+	selection, ok := info.Selections[sel]
+	if !ok || selection.Kind() != types.MethodVal {
+		return ""
+	}
+	recv := selection.Recv()
+	if !types.Implements(recv, client) && !types.Implements(types.NewPointer(recv), client) {
+		return ""
+	}
+	return sel.Sel.Name
+}
+
+// ConstantString returns the value of a constant string expression.
+func ConstantString(info *types.Info, expr ast.Expr) (string, bool) {
+	tv, ok := info.Types[expr]
+	if !ok || tv.Value == nil || tv.Value.Kind() != constant.String {
+		return "", false
+	}
+	return constant.StringVal(tv.Value), true
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	client := ClientInterface(pass.Pkg)
+	if client == nil {
+		return nil, nil
+	}
+
+	var allowed []string
+	for _, prefix := range strings.Split(prefixes, ",") {
+		if prefix = strings.TrimSpace(prefix); prefix != "" {
+			allowed = append(allowed, prefix)
+		}
+	}
+
+	insp := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	insp.Preorder([]ast.Node{(*ast.CallExpr)(nil)}, func(n ast.Node) {
+		call := n.(*ast.CallExpr)
+		method := ClientMethod(pass.TypesInfo, client, call)
+		if method == "" || len(call.Args) == 0 {
+			return
+		}
+
+		switch {
+		case NameMethods[method]:
+			if name, ok := ConstantString(pass.TypesInfo, call.Args[0]); ok {
+				checkName(pass, call.Args[0], name, allowed)
+			}
+		case method == "WithTags":
+			checkTags(pass, call.Args[0])
+		case method == "WithRate":
+			checkRate(pass, call.Args[0])
+		}
+	})
+
+	return nil, nil
+}
+
+// checkName reports metric names which do not follow conventions.
+func checkName(pass *analysis.Pass, node ast.Node, name string, allowed []string) {
+	switch {
+	case !datadogName.MatchString(name):
+		pass.Reportf(node.Pos(), "metric name %q must start with a letter and contain only letters, numbers, underscores, and periods", name)
+	case !conventionalName.MatchString(name):
+		pass.Reportf(node.Pos(), "metric name %q should be lowercase and dot-separated", name)
+	}
+
+	if len(name) > maxLength {
+		pass.Reportf(node.Pos(), "metric name %q is longer than %d characters", name, maxLength)
+	}
+
+	if len(allowed) > 0 {
+		for _, prefix := range allowed {
+			if strings.HasPrefix(name, prefix) {
+				return
+			}
+		}
+		pass.Reportf(node.Pos(), "metric name %q does not start with an allowed prefix (%s)", name, strings.Join(allowed, ", "))
+	}
+}
+
+// checkTags reports non-constant tag keys and likely unbounded tag values in
+// map literals.
+func checkTags(pass *analysis.Pass, expr ast.Expr) {
+	lit, ok := ast.Unparen(expr).(*ast.CompositeLit)
+	if !ok {
+		return
+	}
+
+	for _, elt := range lit.Elts {
+		kv, ok := elt.(*ast.KeyValueExpr)
+		if !ok {
+			continue
+		}
+		key, ok := ConstantString(pass.TypesInfo, kv.Key)
+		if !ok {
+			pass.Reportf(kv.Key.Pos(), "tag key should be a constant")
+			continue
+		}
+		if checkUnbounded {
+			if source := unboundedSource(pass.TypesInfo, kv.Value); source != "" {
+				pass.Reportf(kv.Value.Pos(), "tag %q value comes from %s, which is likely unbounded", key, source)
+			}
+		}
+	}
+}
+
+// unboundedSource describes why an expression likely holds an unbounded
+// value, or returns an empty string.
+func unboundedSource(info *types.Info, expr ast.Expr) string {
+	if _, ok := ConstantString(info, expr); ok {
+		return ""
+	}
+
+	var source string
+	ast.Inspect(expr, func(n ast.Node) bool {
+		if source != "" {
+			return false
+		}
+		switch t := n.(type) {
+		case *ast.CallExpr:
+			if sel, ok := t.Fun.(*ast.SelectorExpr); ok {
+				if sel.Sel.Name == "Error" && len(t.Args) == 0 {
+					source = "an error message"
+					return false
+				}
+				if ident, ok := sel.X.(*ast.Ident); ok {
+					if pkg, ok := info.Uses[ident].(*types.PkgName); ok && pkg.Imported().Path() == "time" && sel.Sel.Name == "Now" {
+						source = "the current time"
+						return false
+					}
+				}
+			}
+		case *ast.SelectorExpr:
+			switch t.Sel.Name {
+			case "URL", "RequestURI", "RemoteAddr":
+				source = types.ExprString(t)
+				return false
+			}
+			if unboundedName.MatchString(t.Sel.Name) {
+				source = types.ExprString(t)
+				return false
+			}
+		case *ast.Ident:
+			if _, ok := info.Uses[t].(*types.Var); ok && unboundedName.MatchString(t.Name) {
+				source = t.Name
+				return false
+			}
+		}
+		return true
+	})
+	return source
+}
+
+// checkRate reports constant sample rates outside of (0, 1].
+func checkRate(pass *analysis.Pass, expr ast.Expr) {
+	tv, ok := pass.TypesInfo.Types[expr]
+	if !ok || tv.Value == nil {
+		return
+	}
+	rate := constant.ToFloat(tv.Value)
+	if rate.Kind() != constant.Float && rate.Kind() != constant.Int {
+		return
+	}
+	if constant.Compare(rate, token.LEQ, constant.MakeInt64(0)) || constant.Compare(rate, token.GTR, constant.MakeInt64(1)) {
+		pass.Reportf(expr.Pos(), "sample rate %s must be greater than 0 and at most 1", tv.Value)
+	}
+}
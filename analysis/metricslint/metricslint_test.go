@@ -0,0 +1,24 @@
+package metricslint_test
+
+import (
+	"testing"
+
+	"github.com/istreamlabs/go-metrics/analysis/metricslint"
+	"golang.org/x/tools/go/analysis/analysistest"
+)
+
+func TestAnalyzer(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), metricslint.Analyzer, "example")
+}
+
+func TestAnalyzerFlags(t *testing.T) {
+	flags := metricslint.Analyzer.Flags
+	flags.Set("prefixes", "myapp.")
+	flags.Set("max-length", "20")
+	defer func() {
+		flags.Set("prefixes", "")
+		flags.Set("max-length", "200")
+	}()
+
+	analysistest.Run(t, analysistest.TestData(), metricslint.Analyzer, "prefixed")
+}
@@ -0,0 +1,63 @@
+package example
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/istreamlabs/go-metrics/metrics"
+)
+
+const requests = "http.requests"
+
+type notClient struct{}
+
+func (notClient) Incr(name string) {}
+
+func names(client metrics.Client, null *metrics.NullClient, dynamic string) {
+	client.Incr(requests)
+	client.Incr("http.requests.total")
+	client.Count("HTTP.Requests", 1)          // want `metric name "HTTP.Requests" should be lowercase and dot-separated`
+	client.Gauge("queue-depth", 1)            // want `metric name "queue-depth" must start with a letter`
+	client.Timing("1st.request", time.Second) // want `metric name "1st.request" must start with a letter`
+	client.Histogram("http..size", 1)         // want `metric name "http..size" should be lowercase and dot-separated`
+	null.Distribution("Latency", 1)           // want `metric name "Latency" should be lowercase and dot-separated`
+	client.Incr(dynamic)
+	notClient{}.Incr("Not-A-Metric")
+}
+
+func tags(client metrics.Client, r *http.Request, err error, userID string, key string, status string) {
+	client.WithTags(map[string]string{"status": status, "method": r.Method}).Incr("http.requests")
+	client.WithTags(map[string]string{key: "value"}) // want `tag key should be a constant`
+	client.WithTags(map[string]string{
+		"user":  userID,                  // want `tag "user" value comes from userID, which is likely unbounded`
+		"path":  r.URL.Path,              // want `tag "path" value comes from r.URL.Path, which is likely unbounded`
+		"error": err.Error(),             // want `tag "error" value comes from an error message, which is likely unbounded`
+		"time":  time.Now().String(),     // want `tag "time" value comes from the current time, which is likely unbounded`
+		"other": errors.New("x").Error(), // want `tag "other" value comes from an error message, which is likely unbounded`
+	})
+}
+
+func boundedNames(client metrics.Client, valid, void, paid, android, skip, zip, ship, membership, user_id, clientIp string) {
+	client.WithTags(map[string]string{
+		"valid":      valid,
+		"void":       void,
+		"paid":       paid,
+		"android":    android,
+		"skip":       skip,
+		"zip":        zip,
+		"ship":       ship,
+		"membership": membership,
+		"user":       user_id,  // want `tag "user" value comes from user_id, which is likely unbounded`
+		"client":     clientIp, // want `tag "client" value comes from clientIp, which is likely unbounded`
+	})
+}
+
+func rates(client metrics.Client, rate float64) {
+	client.WithRate(0.5)
+	client.WithRate(1)
+	client.WithRate(rate)
+	client.WithRate(0)   // want `sample rate 0 must be greater than 0 and at most 1`
+	client.WithRate(1.5) // want `sample rate 1.5 must be greater than 0 and at most 1`
+	client.WithRate(-1)  // want `sample rate -1 must be greater than 0 and at most 1`
+}
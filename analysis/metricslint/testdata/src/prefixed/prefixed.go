@@ -0,0 +1,9 @@
+package prefixed
+
+import "github.com/istreamlabs/go-metrics/metrics"
+
+func prefixes(client metrics.Client) {
+	client.Incr("myapp.requests")
+	client.Incr("other.requests")                // want `metric name "other.requests" does not start with an allowed prefix \(myapp\.\)`
+	client.Incr("myapp.a.very.long.metric.name") // want `metric name "myapp.a.very.long.metric.name" is longer than 20 characters`
+}
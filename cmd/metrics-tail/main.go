@@ -0,0 +1,313 @@
+// Command metrics-tail is a local DogStatsD listener which pretty-prints the
+// metrics, events, and service checks it receives. It is useful when running
+// services configured with a `DataDogClient` locally without an agent:
+//
+//   go run github.com/istreamlabs/go-metrics/cmd/metrics-tail
+//
+// By default it listens for UDP on `:8125` and prints each datagram using the
+// same format as `LoggerClient`. Datagrams can be filtered by name and tags,
+// printed as JSON, or aggregated into a periodic summary table:
+//
+//   # Only show requests metrics for production, listening on a Unix socket too.
+//   metrics-tail -socket /var/run/datadog/dsd.socket -name '^myapp\.requests' -tag env:prod
+//
+//   # Print a summary every ten seconds instead of each datagram.
+//   metrics-tail -summary 10s -quiet
+//
+//   # Output newline-delimited JSON.
+//   metrics-tail -json
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/istreamlabs/go-metrics/metrics"
+	"github.com/mattn/go-isatty"
+)
+
+// tagFlags collects repeated `-tag` flags.
+type tagFlags []string
+
+func (t *tagFlags) String() string {
+	return strings.Join(*t, ",")
+}
+
+func (t *tagFlags) Set(value string) error {
+	*t = append(*t, value)
+	return nil
+}
+
+// config contains the parsed command line options.
+type config struct {
+	addr    string
+	socket  string
+	name    *regexp.Regexp
+	tags    []string
+	summary time.Duration
+	json    bool
+	quiet   bool
+	color   string
+}
+
+func main() {
+	var tags tagFlags
+	cfg := config{}
+	name := ""
+	flag.StringVar(&cfg.addr, "addr", ":8125", "UDP address to listen on, or empty to disable")
+	flag.StringVar(&cfg.socket, "socket", "", "Unix datagram socket path to listen on")
+	flag.StringVar(&name, "name", "", "only show metrics, events, and service checks whose name matches this regular expression")
+	flag.Var(&tags, "tag", "only show datagrams with this `key:value` tag, or `key` for any value (repeatable)")
+	flag.DurationVar(&cfg.summary, "summary", 0, "print an aggregated summary table at this interval")
+	flag.BoolVar(&cfg.json, "json", false, "print datagrams as newline-delimited JSON")
+	flag.BoolVar(&cfg.quiet, "quiet", false, "do not print individual datagrams, e.g. when only a summary is wanted")
+	flag.StringVar(&cfg.color, "color", "auto", "colorize output: auto, always, or never")
+	flag.Parse()
+
+	if err := checkColor(cfg.color); err != nil {
+		fmt.Fprintln(flag.CommandLine.Output(), err)
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if name != "" {
+		re, err := regexp.Compile(name)
+		if err != nil {
+			log.Fatalf("Invalid name filter: %v", err)
+		}
+		cfg.name = re
+	}
+	cfg.tags = tags
+
+	if err := run(cfg, os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// packet is a received DogStatsD packet.
+type packet struct {
+	data []byte
+}
+
+// run listens for datagrams and prints them until interrupted.
+func run(cfg config, out io.Writer) error {
+	packets := make(chan packet, 1024)
+
+	var conns []net.PacketConn
+	if cfg.addr != "" {
+		conn, err := net.ListenPacket("udp", cfg.addr)
+		if err != nil {
+			return err
+		}
+		conns = append(conns, conn)
+		fmt.Fprintf(os.Stderr, "Listening on udp %s\n", conn.LocalAddr())
+	}
+	if cfg.socket != "" {
+		conn, err := net.ListenPacket("unixgram", cfg.socket)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(cfg.socket)
+		conns = append(conns, conn)
+		fmt.Fprintf(os.Stderr, "Listening on unixgram %s\n", cfg.socket)
+	}
+	if len(conns) == 0 {
+		return fmt.Errorf("nothing to listen on, set -addr or -socket")
+	}
+
+	for _, conn := range conns {
+		defer conn.Close()
+		go read(conn, packets)
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+
+	var tick <-chan time.Time
+	if cfg.summary > 0 {
+		ticker := time.NewTicker(cfg.summary)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	p := newPrinter(cfg, out)
+	for {
+		select {
+		case pkt := <-packets:
+			p.packet(pkt.data)
+		case <-tick:
+			p.flushSummary()
+		case <-interrupt:
+			if cfg.summary > 0 {
+				p.flushSummary()
+			}
+			return nil
+		}
+	}
+}
+
+// read sends packets from a connection to a channel until it is closed.
+func read(conn net.PacketConn, packets chan<- packet) {
+	buf := make([]byte, 65535)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		data := make([]byte, n)
+		copy(data, buf[:n])
+		packets <- packet{data}
+	}
+}
+
+// printer filters, prints, and aggregates datagrams.
+type printer struct {
+	cfg     config
+	out     io.Writer
+	logger  *metrics.LoggerClient
+	encoder *json.Encoder
+	summary *summary
+}
+
+// checkColor returns an error if the `-color` flag value is not supported.
+func checkColor(color string) error {
+	switch color {
+	case "auto", "always", "never":
+		return nil
+	}
+	return fmt.Errorf("invalid value %q for -color: must be auto, always, or never", color)
+}
+
+// isTerminal returns whether `out` is a terminal.
+func isTerminal(out io.Writer) bool {
+	f, ok := out.(*os.File)
+	return ok && (isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd()))
+}
+
+func newPrinter(cfg config, out io.Writer) *printer {
+	logger := metrics.NewLoggerClient(log.New(out, "", 0))
+	if cfg.color == "always" || (cfg.color == "auto" && isTerminal(out)) {
+		logger = logger.Colorized()
+	}
+
+	return &printer{
+		cfg:     cfg,
+		out:     out,
+		logger:  logger,
+		encoder: json.NewEncoder(out),
+		summary: newSummary(),
+	}
+}
+
+// packet parses and handles a single packet.
+func (p *printer) packet(data []byte) {
+	datagrams, err := metrics.ParseDogStatsD(data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	}
+
+	for _, d := range datagrams {
+		if !matches(p.cfg, d) {
+			continue
+		}
+		if p.cfg.summary > 0 {
+			p.summary.add(d)
+		}
+		if p.cfg.quiet {
+			continue
+		}
+		if p.cfg.json {
+			if err := p.encoder.Encode(toJSON(d)); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			}
+			continue
+		}
+		p.logger.Datagram(d)
+	}
+}
+
+// flushSummary prints and resets the summary table.
+func (p *printer) flushSummary() {
+	if p.cfg.json {
+		p.summary.writeJSON(p.encoder)
+	} else {
+		p.summary.write(p.out)
+	}
+	p.summary = newSummary()
+}
+
+// datagramName returns the metric name, event title, or service check name.
+func datagramName(d metrics.Datagram) string {
+	if d.Kind == metrics.DatagramEvent {
+		return d.Event.Title
+	}
+	return d.Name
+}
+
+// matches returns whether a datagram passes the name and tag filters.
+func matches(cfg config, d metrics.Datagram) bool {
+	if cfg.name != nil && !cfg.name.MatchString(datagramName(d)) {
+		return false
+	}
+
+FILTERS:
+	for _, filter := range cfg.tags {
+		for _, tag := range d.Tags {
+			if tag == filter || (!strings.Contains(filter, ":") && strings.HasPrefix(tag, filter+":")) {
+				continue FILTERS
+			}
+		}
+		return false
+	}
+
+	return true
+}
+
+// jsonDatagram is the JSON output format of a datagram.
+type jsonDatagram struct {
+	Kind    string    `json:"kind"`
+	Name    string    `json:"name"`
+	Type    string    `json:"type,omitempty"`
+	Values  []float64 `json:"values,omitempty"`
+	Member  string    `json:"member,omitempty"`
+	Rate    float64   `json:"rate,omitempty"`
+	Text    string    `json:"text,omitempty"`
+	Alert   string    `json:"alertType,omitempty"`
+	Status  *int      `json:"status,omitempty"`
+	Message string    `json:"message,omitempty"`
+	Tags    []string  `json:"tags"`
+}
+
+func toJSON(d metrics.Datagram) jsonDatagram {
+	j := jsonDatagram{
+		Kind:   d.Kind,
+		Name:   datagramName(d),
+		Type:   d.Type,
+		Values: d.Values,
+		Member: d.Member,
+		Rate:   d.Rate,
+		Tags:   d.Tags,
+	}
+	if j.Tags == nil {
+		j.Tags = []string{}
+	}
+	switch d.Kind {
+	case metrics.DatagramEvent:
+		j.Text = d.Event.Text
+		j.Alert = string(d.Event.AlertType)
+	case metrics.DatagramServiceCheck:
+		status := int(d.ServiceCheck.Status)
+		j.Status = &status
+		j.Message = d.ServiceCheck.Message
+	}
+	return j
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testPacket = "myapp.requests:1|c|#env:prod,status:200\n" +
+	"myapp.requests:1|c|#env:staging,status:200\n" +
+	"myapp.latency:250|ms|#env:prod\n" +
+	"myapp.latency:750|ms|#env:prod\n" +
+	"_e{6,2}:deploy|v1|t:error|#env:prod\n" +
+	"_sc|db.health|2|#env:prod|m:down"
+
+func TestPrinterFilters(t *testing.T) {
+	var out bytes.Buffer
+	p := newPrinter(config{
+		name:  regexp.MustCompile(`^myapp\.`),
+		tags:  []string{"env:prod", "status"},
+		color: "never",
+	}, &out)
+	p.packet([]byte(testPacket))
+
+	expected := "Count myapp.requests:1 map[env:prod status:200]\n"
+	if out.String() != expected {
+		t.Fatalf("Expected %q but got %q", expected, out.String())
+	}
+}
+
+func TestPrinterJSON(t *testing.T) {
+	var out bytes.Buffer
+	p := newPrinter(config{json: true, color: "never"}, &out)
+	p.packet([]byte(testPacket))
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 6 {
+		t.Fatalf("Expected 6 JSON lines but got %d: %s", len(lines), out.String())
+	}
+	expected := `{"kind":"service_check","name":"db.health","status":2,"message":"down","tags":["env:prod"]}`
+	if lines[5] != expected {
+		t.Fatalf("Expected %s but got %s", expected, lines[5])
+	}
+}
+
+func TestPrinterSummary(t *testing.T) {
+	var out bytes.Buffer
+	p := newPrinter(config{summary: time.Second, quiet: true, tags: []string{"env:prod"}, color: "never"}, &out)
+	p.packet([]byte(testPacket))
+	p.flushSummary()
+
+	expected := "NAME            TYPE           COUNT  SUM   MIN  MAX  LAST  TAGS\n" +
+		"db.health       service_check  1      2     2    2    2     env:prod\n" +
+		"deploy          event          1      1     1    1    1     env:prod\n" +
+		"myapp.latency   Timing         2      1000  250  750  750   env:prod\n" +
+		"myapp.requests  Count          1      1     1    1    1     env:prod,status:200\n"
+	if out.String() != expected {
+		t.Fatalf("Expected:\n%s\nbut got:\n%s", expected, out.String())
+	}
+
+	// The summary is reset after each flush.
+	out.Reset()
+	p.flushSummary()
+	if strings.Count(out.String(), "\n") != 1 {
+		t.Fatalf("Expected an empty summary but got:\n%s", out.String())
+	}
+}
+
+func TestPrinterColor(t *testing.T) {
+	for _, color := range []string{"auto", "always", "never"} {
+		if err := checkColor(color); err != nil {
+			t.Fatalf("Expected %q to be valid but got %v", color, err)
+		}
+	}
+	if err := checkColor("yes"); err == nil {
+		t.Fatal("Expected an error for an unknown color")
+	}
+
+	// A buffer is not a terminal, so auto does not colorize.
+	var out bytes.Buffer
+	p := newPrinter(config{color: "auto"}, &out)
+	p.packet([]byte("myapp.requests:1|c"))
+	if strings.Contains(out.String(), "\x1b[") {
+		t.Fatalf("Expected no colors but got %q", out.String())
+	}
+
+	out.Reset()
+	p = newPrinter(config{color: "always"}, &out)
+	p.packet([]byte("myapp.requests:1|c"))
+	if !strings.Contains(out.String(), "\x1b[") {
+		t.Fatalf("Expected colors but got %q", out.String())
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/istreamlabs/go-metrics/metrics"
+)
+
+// row aggregates all datagrams with the same kind, name, type, and tags.
+type row struct {
+	Kind  string   `json:"kind"`
+	Name  string   `json:"name"`
+	Type  string   `json:"type,omitempty"`
+	Tags  []string `json:"tags"`
+	Count int      `json:"count"`
+	Sum   float64  `json:"sum"`
+	Min   float64  `json:"min"`
+	Max   float64  `json:"max"`
+	Last  float64  `json:"last"`
+}
+
+// summary aggregates datagrams received during an interval.
+type summary struct {
+	rows map[string]*row
+}
+
+func newSummary() *summary {
+	return &summary{rows: make(map[string]*row)}
+}
+
+// add aggregates a datagram into the summary.
+func (s *summary) add(d metrics.Datagram) {
+	tags := append([]string{}, d.Tags...)
+	sort.Strings(tags)
+	key := strings.Join([]string{d.Kind, datagramName(d), d.Type, strings.Join(tags, ",")}, "|")
+
+	r, ok := s.rows[key]
+	if !ok {
+		r = &row{
+			Kind: d.Kind,
+			Name: datagramName(d),
+			Type: d.Type,
+			Tags: tags,
+			Min:  math.Inf(1),
+			Max:  math.Inf(-1),
+		}
+		s.rows[key] = r
+	}
+
+	values := d.Values
+	if d.Kind == metrics.DatagramServiceCheck {
+		values = []float64{float64(d.ServiceCheck.Status)}
+	} else if d.Kind == metrics.DatagramEvent || d.Type == "Set" {
+		values = []float64{1}
+	}
+
+	for _, v := range values {
+		r.Count++
+		r.Sum += v
+		r.Min = math.Min(r.Min, v)
+		r.Max = math.Max(r.Max, v)
+		r.Last = v
+	}
+}
+
+// sorted returns the rows ordered by name, type, then tags.
+func (s *summary) sorted() []*row {
+	rows := make([]*row, 0, len(s.rows))
+	for _, r := range s.rows {
+		rows = append(rows, r)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Name != rows[j].Name {
+			return rows[i].Name < rows[j].Name
+		}
+		if rows[i].Type != rows[j].Type {
+			return rows[i].Type < rows[j].Type
+		}
+		return strings.Join(rows[i].Tags, ",") < strings.Join(rows[j].Tags, ",")
+	})
+	return rows
+}
+
+// write prints the summary as a table.
+func (s *summary) write(out io.Writer) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tTYPE\tCOUNT\tSUM\tMIN\tMAX\tLAST\tTAGS")
+	for _, r := range s.sorted() {
+		t := r.Type
+		if t == "" {
+			t = r.Kind
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%v\t%v\t%v\t%v\t%s\n", r.Name, t, r.Count, r.Sum, r.Min, r.Max, r.Last, strings.Join(r.Tags, ","))
+	}
+	w.Flush()
+}
+
+// writeJSON prints the summary as a single JSON object.
+func (s *summary) writeJSON(encoder *json.Encoder) {
+	rows := s.sorted()
+	if rows == nil {
+		rows = []*row{}
+	}
+	encoder.Encode(struct {
+		Summary []*row `json:"summary"`
+	}{rows})
+}
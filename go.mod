@@ -1,10 +1,11 @@
 module github.com/istreamlabs/go-metrics
 
-go 1.12
+go 1.15
 
 require (
 	github.com/DataDog/datadog-go/v5 v5.2.0
 	github.com/mattn/go-colorable v0.1.8 // indirect
 	github.com/mattn/go-isatty v0.0.17
 	github.com/mgutz/ansi v0.0.0-20200706080929-d51e80ef957d
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -0,0 +1,273 @@
+// Package grpcmetrics provides gRPC server and client interceptors which emit
+// standard call metrics via a `metrics.Client`:
+//
+//	server := grpc.NewServer(
+//	  grpc.UnaryInterceptor(grpcmetrics.UnaryServerInterceptor(client)),
+//	  grpc.StreamInterceptor(grpcmetrics.StreamServerInterceptor(client)),
+//	)
+//
+//	conn, err := grpc.NewClient(address,
+//	  grpc.WithUnaryInterceptor(grpcmetrics.UnaryClientInterceptor(client)),
+//	  grpc.WithStreamInterceptor(grpcmetrics.StreamClientInterceptor(client)),
+//	)
+//
+// The server interceptors emit the following metrics, tagged with `service`,
+// `method`, and `code` (e.g. `OK` or `NotFound`):
+//
+//   - `grpc.server.requests` count of completed calls
+//   - `grpc.server.latency` timing of each call
+//   - `grpc.server.msg.received` count of messages received
+//   - `grpc.server.msg.sent` count of messages sent
+//
+// The client interceptors emit the same metrics with a `grpc.client` prefix.
+// This package lives in its own module to avoid adding a gRPC dependency to
+// the metrics package.
+package grpcmetrics
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/istreamlabs/go-metrics/metrics"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// Options contains the configuration options for the interceptors.
+type Options struct {
+	// Prefix for metric names, defaulting to `grpc.server` or `grpc.client`.
+	Prefix string
+
+	// Rates maps full method names like `/pkg.Service/Method` to sample rates.
+	Rates map[string]float64
+}
+
+// Option is an interceptor option.
+type Option func(*Options)
+
+// WithPrefix sets the metric name prefix.
+func WithPrefix(prefix string) Option {
+	return func(o *Options) {
+		o.Prefix = prefix
+	}
+}
+
+// WithMethodRate sets the sample rate for a full method name like
+// `/pkg.Service/Method`, e.g. for high-throughput health checks.
+func WithMethodRate(fullMethod string, rate float64) Option {
+	return func(o *Options) {
+		o.Rates[fullMethod] = rate
+	}
+}
+
+func resolveOptions(prefix string, options []Option) *Options {
+	o := &Options{
+		Prefix: prefix,
+		Rates:  make(map[string]float64),
+	}
+	for _, option := range options {
+		option(o)
+	}
+	return o
+}
+
+// splitMethod splits a full method name into its service and method.
+func splitMethod(fullMethod string) (string, string) {
+	fullMethod = strings.TrimPrefix(fullMethod, "/")
+	if i := strings.LastIndex(fullMethod, "/"); i != -1 {
+		return fullMethod[:i], fullMethod[i+1:]
+	}
+	return "unknown", fullMethod
+}
+
+// call tracks the metrics of a single RPC.
+type call struct {
+	client   metrics.Client
+	prefix   string
+	start    time.Time
+	once     sync.Once
+	lock     sync.Mutex
+	received int64
+	sent     int64
+
+	// stop cancels finishing the call when its context is done, if set.
+	stop func() bool
+}
+
+// newCall creates a new call tagged with the service and method, using the
+// method's sample rate if one is configured.
+func newCall(client metrics.Client, o *Options, fullMethod string) *call {
+	if rate, ok := o.Rates[fullMethod]; ok {
+		client = client.WithRate(rate)
+	}
+	service, method := splitMethod(fullMethod)
+	return &call{
+		client: client.WithTags(map[string]string{
+			"service": service,
+			"method":  method,
+		}),
+		prefix: o.Prefix,
+		start:  time.Now(),
+	}
+}
+
+func (c *call) recv() {
+	c.lock.Lock()
+	c.received++
+	c.lock.Unlock()
+}
+
+func (c *call) send() {
+	c.lock.Lock()
+	c.sent++
+	c.lock.Unlock()
+}
+
+// finish emits the call metrics once, tagged with the status code of `err`.
+func (c *call) finish(err error) {
+	c.once.Do(func() {
+		c.lock.Lock()
+		received, sent, stop := c.received, c.sent, c.stop
+		c.lock.Unlock()
+		if stop != nil {
+			stop()
+		}
+
+		tagged := c.client.WithTags(map[string]string{
+			"code": status.Code(err).String(),
+		})
+		tagged.Incr(c.prefix + ".requests")
+		tagged.Timing(c.prefix+".latency", time.Since(c.start))
+		tagged.Count(c.prefix+".msg.received", received)
+		tagged.Count(c.prefix+".msg.sent", sent)
+	})
+}
+
+// UnaryServerInterceptor returns an interceptor which emits metrics for
+// unary calls handled by a server.
+func UnaryServerInterceptor(client metrics.Client, options ...Option) grpc.UnaryServerInterceptor {
+	o := resolveOptions("grpc.server", options)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		c := newCall(client, o, info.FullMethod)
+		c.recv()
+		resp, err := handler(ctx, req)
+		if err == nil {
+			c.send()
+		}
+		c.finish(err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor returns an interceptor which emits metrics for
+// streaming calls handled by a server.
+func StreamServerInterceptor(client metrics.Client, options ...Option) grpc.StreamServerInterceptor {
+	o := resolveOptions("grpc.server", options)
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		c := newCall(client, o, info.FullMethod)
+		err := handler(srv, &serverStream{ServerStream: ss, call: c})
+		c.finish(err)
+		return err
+	}
+}
+
+// serverStream counts messages on a server stream.
+type serverStream struct {
+	grpc.ServerStream
+	call *call
+}
+
+func (s *serverStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.call.send()
+	}
+	return err
+}
+
+func (s *serverStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.call.recv()
+	}
+	return err
+}
+
+// UnaryClientInterceptor returns an interceptor which emits metrics for
+// unary calls made by a client.
+func UnaryClientInterceptor(client metrics.Client, options ...Option) grpc.UnaryClientInterceptor {
+	o := resolveOptions("grpc.client", options)
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		c := newCall(client, o, method)
+		c.send()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		if err == nil {
+			c.recv()
+		}
+		c.finish(err)
+		return err
+	}
+}
+
+// StreamClientInterceptor returns an interceptor which emits metrics for
+// streaming calls made by a client. Metrics are emitted when receiving
+// returns `io.EOF` or an error, when the single response of a
+// client-streaming call is received, or when the call's context is canceled
+// or times out. Streams which are abandoned without any of these happening,
+// which leaks the stream in gRPC as well, emit no metrics.
+func StreamClientInterceptor(client metrics.Client, options ...Option) grpc.StreamClientInterceptor {
+	o := resolveOptions("grpc.client", options)
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		c := newCall(client, o, method)
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			c.finish(err)
+			return nil, err
+		}
+
+		stop := context.AfterFunc(ctx, func() {
+			c.finish(status.FromContextError(ctx.Err()).Err())
+		})
+		c.lock.Lock()
+		c.stop = stop
+		c.lock.Unlock()
+
+		return &clientStream{ClientStream: cs, call: c, desc: desc}, nil
+	}
+}
+
+// clientStream counts messages on a client stream.
+type clientStream struct {
+	grpc.ClientStream
+	call *call
+	desc *grpc.StreamDesc
+}
+
+func (s *clientStream) SendMsg(m interface{}) error {
+	err := s.ClientStream.SendMsg(m)
+	if err == nil {
+		s.call.send()
+	}
+	return err
+}
+
+func (s *clientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	switch {
+	case err == nil:
+		s.call.recv()
+		if !s.desc.ServerStreams {
+			// Client-streaming calls end with a single response rather than
+			// `io.EOF`, e.g. from generated `CloseAndRecv` methods.
+			s.call.finish(nil)
+		}
+	case err == io.EOF:
+		s.call.finish(nil)
+	default:
+		s.call.finish(err)
+	}
+	return err
+}
@@ -0,0 +1,245 @@
+package grpcmetrics_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/istreamlabs/go-metrics/grpcmetrics"
+	"github.com/istreamlabs/go-metrics/metrics"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// echoService is a test service reusing the health check messages so that no
+// generated code is needed.
+var echoService = grpc.ServiceDesc{
+	ServiceName: "test.Echo",
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{{
+		MethodName: "Check",
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			req := &healthpb.HealthCheckRequest{}
+			if err := dec(req); err != nil {
+				return nil, err
+			}
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				if req.(*healthpb.HealthCheckRequest).Service == "missing" {
+					return nil, status.Error(codes.NotFound, "missing")
+				}
+				return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+			}
+			return interceptor(ctx, req, &grpc.UnaryServerInfo{FullMethod: "/test.Echo/Check"}, handler)
+		},
+	}},
+	Streams: []grpc.StreamDesc{{
+		StreamName:    "Echo",
+		ServerStreams: true,
+		ClientStreams: true,
+		Handler: func(srv interface{}, stream grpc.ServerStream) error {
+			for {
+				req := &healthpb.HealthCheckRequest{}
+				if err := stream.RecvMsg(req); err == io.EOF {
+					return nil
+				} else if err != nil {
+					return err
+				}
+				if err := stream.SendMsg(&healthpb.HealthCheckResponse{}); err != nil {
+					return err
+				}
+			}
+		},
+	}, {
+		StreamName:    "Collect",
+		ClientStreams: true,
+		Handler: func(srv interface{}, stream grpc.ServerStream) error {
+			for {
+				req := &healthpb.HealthCheckRequest{}
+				if err := stream.RecvMsg(req); err == io.EOF {
+					return stream.SendMsg(&healthpb.HealthCheckResponse{})
+				} else if err != nil {
+					return err
+				}
+			}
+		},
+	}},
+}
+
+var (
+	echoStream    = &grpc.StreamDesc{StreamName: "Echo", ServerStreams: true, ClientStreams: true}
+	collectStream = &grpc.StreamDesc{StreamName: "Collect", ClientStreams: true}
+)
+
+// setup starts a server over an in-memory listener and returns a connected
+// client. Both are instrumented with their own recorder.
+func setup(t *testing.T, options ...grpcmetrics.Option) (*grpc.ClientConn, *metrics.RecorderClient, *metrics.RecorderClient) {
+	serverRecorder := metrics.NewRecorderClient().WithTest(t)
+	clientRecorder := metrics.NewRecorderClient().WithTest(t)
+
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(grpcmetrics.UnaryServerInterceptor(serverRecorder, options...)),
+		grpc.StreamInterceptor(grpcmetrics.StreamServerInterceptor(serverRecorder, options...)),
+	)
+	server.RegisterService(&echoService, struct{}{})
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(grpcmetrics.UnaryClientInterceptor(clientRecorder, options...)),
+		grpc.WithStreamInterceptor(grpcmetrics.StreamClientInterceptor(clientRecorder, options...)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return conn, serverRecorder, clientRecorder
+}
+
+func TestUnary(t *testing.T) {
+	conn, server, client := setup(t)
+	ctx := context.Background()
+
+	if err := conn.Invoke(ctx, "/test.Echo/Check", &healthpb.HealthCheckRequest{}, &healthpb.HealthCheckResponse{}); err != nil {
+		t.Fatal(err)
+	}
+	err := conn.Invoke(ctx, "/test.Echo/Check", &healthpb.HealthCheckRequest{Service: "missing"}, &healthpb.HealthCheckResponse{})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("Expected NotFound but got %v", err)
+	}
+
+	for prefix, recorder := range map[string]*metrics.RecorderClient{"grpc.server": server, "grpc.client": client} {
+		ok := map[string]string{"service": "test.Echo", "method": "Check", "code": "OK"}
+		recorder.Expect(prefix + ".requests").Tags(ok).Value(1)
+		recorder.Expect(prefix + ".latency").Tags(ok)
+		recorder.Expect(prefix + ".msg.received").Tags(ok).Value(1)
+		recorder.Expect(prefix + ".msg.sent").Tags(ok).Value(1)
+		recorder.Expect(prefix + ".requests").Tags(map[string]string{"service": "test.Echo", "method": "Check", "code": "NotFound"})
+	}
+	server.Expect("grpc.server.msg.sent").Tag("code", "NotFound").Value(0)
+	client.Expect("grpc.client.msg.received").Tag("code", "NotFound").Value(0)
+}
+
+func TestStream(t *testing.T) {
+	conn, server, client := setup(t)
+
+	// The server finishes asynchronously after the client sees EOF.
+	finished := make(chan metrics.Call, 1)
+	unsubscribe := server.SubscribeChan(finished, metrics.Match().Contains("grpc.server.requests"))
+	defer unsubscribe()
+
+	stream, err := conn.NewStream(context.Background(), echoStream, "/test.Echo/Echo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := stream.SendMsg(&healthpb.HealthCheckRequest{}); err != nil {
+			t.Fatal(err)
+		}
+		if err := stream.RecvMsg(&healthpb.HealthCheckResponse{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.RecvMsg(&healthpb.HealthCheckResponse{}); err != io.EOF {
+		t.Fatalf("Expected EOF but got %v", err)
+	}
+
+	ok := map[string]string{"service": "test.Echo", "method": "Echo", "code": "OK"}
+	client.Expect("grpc.client.requests").Tags(ok).Value(1)
+	client.Expect("grpc.client.latency").Tags(ok)
+	client.Expect("grpc.client.msg.sent").Tags(ok).Value(3)
+	client.Expect("grpc.client.msg.received").Tags(ok).Value(3)
+
+	select {
+	case <-finished:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for server metrics")
+	}
+	server.Expect("grpc.server.requests").Tags(ok).Value(1)
+	server.Expect("grpc.server.msg.received").Tags(ok).Value(3)
+	server.Expect("grpc.server.msg.sent").Tags(ok).Value(3)
+}
+
+func TestClientStream(t *testing.T) {
+	conn, _, client := setup(t)
+
+	// Mirrors a generated `CloseAndRecv`, which never sees `io.EOF`.
+	stream, err := conn.NewStream(context.Background(), collectStream, "/test.Echo/Collect")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := stream.SendMsg(&healthpb.HealthCheckRequest{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.RecvMsg(&healthpb.HealthCheckResponse{}); err != nil {
+		t.Fatal(err)
+	}
+
+	ok := map[string]string{"service": "test.Echo", "method": "Collect", "code": "OK"}
+	client.Expect("grpc.client.requests").Tags(ok).Value(1)
+	client.Expect("grpc.client.latency").Tags(ok)
+	client.Expect("grpc.client.msg.sent").Tags(ok).Value(3)
+	client.Expect("grpc.client.msg.received").Tags(ok).Value(1)
+}
+
+func TestStreamCanceled(t *testing.T) {
+	conn, _, client := setup(t)
+
+	finished := make(chan metrics.Call, 1)
+	unsubscribe := client.SubscribeChan(finished, metrics.Match().Contains("grpc.client.requests"))
+	defer unsubscribe()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := conn.NewStream(ctx, echoStream, "/test.Echo/Echo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.SendMsg(&healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The stream is abandoned without receiving, so only the canceled
+	// context ends it.
+	cancel()
+
+	select {
+	case <-finished:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for client metrics")
+	}
+	canceled := map[string]string{"service": "test.Echo", "method": "Echo", "code": "Canceled"}
+	client.Expect("grpc.client.requests").Tags(canceled).Value(1)
+	client.Expect("grpc.client.msg.sent").Tags(canceled).Value(1)
+}
+
+func TestMethodRate(t *testing.T) {
+	conn, server, client := setup(t,
+		grpcmetrics.WithPrefix("rpc"),
+		grpcmetrics.WithMethodRate("/test.Echo/Check", 0.25))
+
+	if err := conn.Invoke(context.Background(), "/test.Echo/Check", &healthpb.HealthCheckRequest{}, &healthpb.HealthCheckResponse{}); err != nil {
+		t.Fatal(err)
+	}
+
+	server.Expect("rpc.requests").Rate(0.25)
+	client.Expect("rpc.latency").Rate(0.25)
+}
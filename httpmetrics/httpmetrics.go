@@ -0,0 +1,370 @@
+// Package httpmetrics provides `net/http` server middleware and a client
+// `http.RoundTripper` which emit standard request metrics via a
+// `metrics.Client`:
+//
+//   mux := http.NewServeMux()
+//   mux.HandleFunc("/users/", usersHandler)
+//
+//   handler := httpmetrics.Handler(client, mux,
+//     httpmetrics.WithRouteName(httpmetrics.ServeMuxRoute(mux)))
+//
+//   httpClient := &http.Client{
+//     Transport: httpmetrics.Transport(client, http.DefaultTransport),
+//   }
+//
+// The server middleware emits the following metrics, tagged with `route`,
+// `method`, and `status_class` (e.g. `2xx`):
+//
+//   - `http.server.requests` count
+//   - `http.server.latency` timing
+//   - `http.server.response.size` histogram in bytes
+//   - `http.server.in_flight` gauge of requests currently being handled
+//
+// The in-flight gauge counts requests across every handler or transport
+// emitting to the same client with the same prefix, so wrapping several
+// routes separately still reports the combined total.
+//
+// The transport emits the same metrics with an `http.client` prefix, with an
+// additional `host` tag and a `status_class` of `error` when the request
+// fails without a response. For responses of unknown length, e.g. chunked
+// responses, the response size is the number of body bytes read and is
+// emitted when the body is read to the end or closed.
+//
+// Routes are used instead of URL paths to avoid creating a new time series for
+// every unique URL. By default the route is `unknown`; use `WithRouteName` to
+// provide an extractor for your router.
+package httpmetrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/istreamlabs/go-metrics/metrics"
+)
+
+// DefaultRoute is the route tag value when no route name extractor is set.
+const DefaultRoute = "unknown"
+
+// Options contains the configuration options for the middleware and
+// transport.
+type Options struct {
+	// Prefix for metric names, defaulting to `http.server` or `http.client`.
+	Prefix string
+
+	// RouteName returns a low-cardinality name for the request's route.
+	RouteName func(r *http.Request) string
+}
+
+// Option is a middleware or transport option.
+type Option func(*Options)
+
+// WithPrefix sets the metric name prefix.
+func WithPrefix(prefix string) Option {
+	return func(o *Options) {
+		o.Prefix = prefix
+	}
+}
+
+// WithRouteName sets the function used to name the route of a request, e.g.
+// the pattern matched by your router.
+func WithRouteName(f func(r *http.Request) string) Option {
+	return func(o *Options) {
+		o.RouteName = f
+	}
+}
+
+// ServeMuxRoute returns a route name extractor which uses the pattern that
+// `mux` matches for the request.
+func ServeMuxRoute(mux *http.ServeMux) func(r *http.Request) string {
+	return func(r *http.Request) string {
+		if _, pattern := mux.Handler(r); pattern != "" {
+			return pattern
+		}
+		return DefaultRoute
+	}
+}
+
+func resolveOptions(prefix string, options []Option) *Options {
+	o := &Options{
+		Prefix: prefix,
+		RouteName: func(r *http.Request) string {
+			return DefaultRoute
+		},
+	}
+	for _, option := range options {
+		option(o)
+	}
+	return o
+}
+
+// inFlightKey identifies an in-flight gauge by client and metric name.
+type inFlightKey struct {
+	client metrics.Client
+	name   string
+}
+
+// inFlightCounters maps each `inFlightKey` to its shared `*int64` counter.
+var inFlightCounters sync.Map
+
+// inFlightCounter returns the counter for the in-flight gauge `name` on
+// `client`. Clients which cannot be used as map keys get their own counter.
+func inFlightCounter(client metrics.Client, name string) *int64 {
+	if !reflect.TypeOf(client).Comparable() {
+		return new(int64)
+	}
+	counter, _ := inFlightCounters.LoadOrStore(inFlightKey{client, name}, new(int64))
+	return counter.(*int64)
+}
+
+// knownMethods are the HTTP methods used as tag values. Others are tagged as
+// `OTHER` to limit cardinality.
+var knownMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodConnect: true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+}
+
+func methodTag(method string) string {
+	if method == "" {
+		return http.MethodGet
+	}
+	if knownMethods[method] {
+		return method
+	}
+	return "OTHER"
+}
+
+// statusClass returns e.g. `2xx` for a status code of `200`.
+func statusClass(status int) string {
+	if status < 100 || status > 599 {
+		return "unknown"
+	}
+	return fmt.Sprintf("%dxx", status/100)
+}
+
+// Middleware returns a function which wraps handlers with `Handler`, for use
+// with routers which support middleware chains.
+func Middleware(client metrics.Client, options ...Option) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return Handler(client, next, options...)
+	}
+}
+
+// Handler wraps an `http.Handler` to emit request metrics.
+func Handler(client metrics.Client, next http.Handler, options ...Option) http.Handler {
+	o := resolveOptions("http.server", options)
+	inFlight := inFlightCounter(client, o.Prefix+".in_flight")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client.Gauge(o.Prefix+".in_flight", float64(atomic.AddInt64(inFlight, 1)))
+		start := time.Now()
+		rw := &responseWriter{ResponseWriter: w}
+		completed := false
+
+		// Metrics are emitted in a deferred function so that they are still
+		// recorded, as a server error, if the handler panics.
+		defer func() {
+			client.Gauge(o.Prefix+".in_flight", float64(atomic.AddInt64(inFlight, -1)))
+
+			if rw.status == 0 {
+				rw.status = http.StatusOK
+				if !completed {
+					rw.status = http.StatusInternalServerError
+				}
+			}
+
+			tagged := client.WithTags(map[string]string{
+				"route":        o.RouteName(r),
+				"method":       methodTag(r.Method),
+				"status_class": statusClass(rw.status),
+			})
+			tagged.Incr(o.Prefix + ".requests")
+			tagged.Timing(o.Prefix+".latency", time.Since(start))
+			tagged.Histogram(o.Prefix+".response.size", float64(rw.size))
+		}()
+
+		next.ServeHTTP(rw, r)
+		completed = true
+	})
+}
+
+// responseWriter records the status code and number of bytes written.
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+	size   int64
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += int64(n)
+	return n, err
+}
+
+// Flush implements `http.Flusher` if the wrapped writer supports it.
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		f.Flush()
+	}
+}
+
+// ReadFrom implements `io.ReaderFrom` so that the wrapped writer can still
+// use optimizations like `sendfile` when serving files.
+func (w *responseWriter) ReadFrom(r io.Reader) (int64, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	var n int64
+	var err error
+	if rf, ok := w.ResponseWriter.(io.ReaderFrom); ok {
+		n, err = rf.ReadFrom(r)
+	} else {
+		n, err = io.Copy(w.ResponseWriter, r)
+	}
+	w.size += n
+	return n, err
+}
+
+// Push implements `http.Pusher` if the wrapped writer supports it.
+func (w *responseWriter) Push(target string, opts *http.PushOptions) error {
+	if p, ok := w.ResponseWriter.(http.Pusher); ok {
+		return p.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
+
+// Hijack implements `http.Hijacker` if the wrapped writer supports it.
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("%T does not support hijacking", w.ResponseWriter)
+	}
+	if w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return h.Hijack()
+}
+
+// Unwrap returns the wrapped writer, for use with `http.ResponseController`.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// transport emits metrics for outbound requests.
+type transport struct {
+	client   metrics.Client
+	next     http.RoundTripper
+	options  *Options
+	inFlight *int64
+}
+
+// Transport wraps an `http.RoundTripper` to emit request metrics for
+// outbound calls. If `next` is `nil` then `http.DefaultTransport` is used.
+func Transport(client metrics.Client, next http.RoundTripper, options ...Option) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	o := resolveOptions("http.client", options)
+	return &transport{
+		client:   client,
+		next:     next,
+		options:  o,
+		inFlight: inFlightCounter(client, o.Prefix+".in_flight"),
+	}
+}
+
+func (t *transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	prefix := t.options.Prefix
+	t.client.Gauge(prefix+".in_flight", float64(atomic.AddInt64(t.inFlight, 1)))
+	defer func() {
+		t.client.Gauge(prefix+".in_flight", float64(atomic.AddInt64(t.inFlight, -1)))
+	}()
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(r)
+
+	class := "error"
+	if err == nil {
+		class = statusClass(resp.StatusCode)
+	}
+	tagged := t.client.WithTags(map[string]string{
+		"host":         r.URL.Host,
+		"route":        t.options.RouteName(r),
+		"method":       methodTag(r.Method),
+		"status_class": class,
+	})
+	tagged.Incr(prefix + ".requests")
+	tagged.Timing(prefix+".latency", time.Since(start))
+	if err == nil {
+		if resp.ContentLength >= 0 {
+			tagged.Histogram(prefix+".response.size", float64(resp.ContentLength))
+		} else if _, ok := resp.Body.(io.Writer); !ok {
+			// Bodies which are also writers, e.g. for protocol upgrades, are
+			// not wrapped so they keep working as connections.
+			resp.Body = &countingBody{
+				ReadCloser: resp.Body,
+				client:     tagged,
+				name:       prefix + ".response.size",
+			}
+		}
+	}
+
+	return resp, err
+}
+
+// countingBody counts the bytes read from a response body of unknown length
+// and emits the size once the body is read to the end or closed.
+type countingBody struct {
+	io.ReadCloser
+	client metrics.Client
+	name   string
+	size   int64
+	once   sync.Once
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	atomic.AddInt64(&b.size, int64(n))
+	if err == io.EOF {
+		b.emit()
+	}
+	return n, err
+}
+
+func (b *countingBody) Close() error {
+	b.emit()
+	return b.ReadCloser.Close()
+}
+
+func (b *countingBody) emit() {
+	b.once.Do(func() {
+		b.client.Histogram(b.name, float64(atomic.LoadInt64(&b.size)))
+	})
+}
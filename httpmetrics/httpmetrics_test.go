@@ -0,0 +1,214 @@
+package httpmetrics_test
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/istreamlabs/go-metrics/httpmetrics"
+	"github.com/istreamlabs/go-metrics/metrics"
+)
+
+func newMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users/", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "hello")
+	})
+	mux.HandleFunc("/fail", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	mux.HandleFunc("/chunked", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "hello ")
+		w.(http.Flusher).Flush()
+		io.WriteString(w, "world")
+	})
+	return mux
+}
+
+func TestHandler(t *testing.T) {
+	recorder := metrics.NewRecorderClient().WithTest(t)
+	mux := newMux()
+	handler := httpmetrics.Handler(recorder, mux, httpmetrics.WithRouteName(httpmetrics.ServeMuxRoute(mux)))
+
+	for _, target := range []string{"/users/1", "/users/2", "/fail"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", target, nil))
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("BREW", "/missing", nil))
+
+	ok := map[string]string{"route": "/users/", "method": "GET", "status_class": "2xx"}
+	recorder.Expect("http.server.requests").MinTimes(2).Tags(ok)
+	recorder.Expect("http.server.latency").MinTimes(2).Tags(ok)
+	recorder.Expect("http.server.response.size").Tags(ok).Value(5)
+
+	recorder.Expect("http.server.requests").Tags(map[string]string{"route": "/fail", "method": "GET", "status_class": "5xx"})
+	recorder.Expect("http.server.response.size").Tag("route", "/fail").Value(0)
+	recorder.Expect("http.server.requests").Tags(map[string]string{"route": "unknown", "method": "OTHER", "status_class": "4xx"})
+
+	recorder.Expect("http.server.in_flight").Value(1)
+	recorder.Expect("http.server.in_flight").Value(0)
+}
+
+func TestHandlerPanic(t *testing.T) {
+	recorder := metrics.NewRecorderClient().WithTest(t)
+	handler := httpmetrics.Handler(recorder, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("Expected the panic to propagate")
+			}
+		}()
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}()
+
+	recorder.Expect("http.server.requests").Tags(map[string]string{"route": "unknown", "method": "GET", "status_class": "5xx"})
+	recorder.Expect("http.server.latency")
+	recorder.Expect("http.server.in_flight").Value(0)
+}
+
+func TestMiddlewareOptions(t *testing.T) {
+	recorder := metrics.NewRecorderClient().WithTest(t)
+	middleware := httpmetrics.Middleware(recorder,
+		httpmetrics.WithPrefix("api"),
+		httpmetrics.WithRouteName(func(r *http.Request) string { return "fixed" }))
+
+	handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.(http.Flusher).Flush()
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/anything", nil))
+
+	recorder.Expect("api.requests").Tags(map[string]string{"route": "fixed", "method": "POST", "status_class": "2xx"})
+	recorder.Expect("api.latency")
+}
+
+func TestHandlerInFlight(t *testing.T) {
+	recorder := metrics.NewRecorderClient().WithTest(t)
+	release := make(chan struct{})
+	started := make(chan struct{}, 2)
+	blocking := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+	})
+
+	// Separately wrapped routes share the same in-flight count.
+	handlers := []http.Handler{
+		httpmetrics.Handler(recorder, blocking),
+		httpmetrics.Handler(recorder, blocking),
+	}
+
+	done := make(chan struct{})
+	for _, handler := range handlers {
+		go func(handler http.Handler) {
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+			done <- struct{}{}
+		}(handler)
+	}
+	<-started
+	<-started
+	close(release)
+	<-done
+	<-done
+
+	recorder.Expect("http.server.in_flight").Value(2)
+	recorder.Expect("http.server.requests").MinTimes(2)
+}
+
+func TestHandlerReadFromPush(t *testing.T) {
+	recorder := metrics.NewRecorderClient().WithTest(t)
+	handler := httpmetrics.Handler(recorder, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := w.(http.Pusher).Push("/style.css", nil); err != http.ErrNotSupported {
+			t.Errorf("Expected push to be unsupported but got %v", err)
+		}
+		w.(io.ReaderFrom).ReadFrom(strings.NewReader("hello"))
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	if w.Body.String() != "hello" {
+		t.Fatalf("Expected body 'hello' but got '%s'", w.Body.String())
+	}
+	recorder.Expect("http.server.response.size").Tag("status_class", "2xx").Value(5)
+}
+
+func TestTransport(t *testing.T) {
+	server := httptest.NewServer(newMux())
+	defer server.Close()
+
+	recorder := metrics.NewRecorderClient().WithTest(t)
+	client := &http.Client{Transport: httpmetrics.Transport(recorder, nil,
+		httpmetrics.WithRouteName(func(r *http.Request) string {
+			return strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)[0]
+		}))}
+
+	for _, path := range []string{"/users/1", "/fail"} {
+		resp, err := client.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+
+	host := strings.TrimPrefix(server.URL, "http://")
+	recorder.Expect("http.client.requests").Tags(map[string]string{"host": host, "route": "users", "method": "GET", "status_class": "2xx"})
+	recorder.Expect("http.client.response.size").Tag("route", "users").Value(5)
+	recorder.Expect("http.client.requests").Tags(map[string]string{"host": host, "route": "fail", "method": "GET", "status_class": "5xx"})
+	recorder.Expect("http.client.latency").MinTimes(2)
+	recorder.Expect("http.client.in_flight").Value(0)
+}
+
+func TestTransportChunked(t *testing.T) {
+	server := httptest.NewServer(newMux())
+	defer server.Close()
+
+	recorder := metrics.NewRecorderClient().WithTest(t)
+	client := &http.Client{Transport: httpmetrics.Transport(recorder, nil)}
+
+	resp, err := client.Get(server.URL + "/chunked")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.ContentLength != -1 {
+		t.Fatalf("Expected a response of unknown length but got %d", resp.ContentLength)
+	}
+	recorder.If("http.client.response.size").Reject()
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "hello world" {
+		t.Fatalf("Unexpected body '%s'", body)
+	}
+
+	recorder.Expect("http.client.response.size").Value(11)
+	if n := len(recorder.If("http.client.response.size").GetCalls()); n != 1 {
+		t.Fatalf("Expected the response size once but got %d", n)
+	}
+}
+
+// failingTransport always returns an error.
+type failingTransport struct{}
+
+func (failingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	time.Sleep(time.Millisecond)
+	return nil, errors.New("connection refused")
+}
+
+func TestTransportError(t *testing.T) {
+	recorder := metrics.NewRecorderClient().WithTest(t)
+	client := &http.Client{Transport: httpmetrics.Transport(recorder, failingTransport{})}
+
+	if _, err := client.Get("http://example.invalid/"); err == nil {
+		t.Fatal("Expected an error")
+	}
+
+	recorder.Expect("http.client.requests").Tags(map[string]string{"host": "example.invalid", "route": "unknown", "method": "GET", "status_class": "error"})
+	recorder.If("http.client.response.size").Reject()
+}
@@ -0,0 +1,39 @@
+// Package levenshtein computes edit distances between strings. It is shared
+// by the recorder's nearest-match diffs and the analysis tools.
+package levenshtein
+
+// Distance returns the edit distance between two strings, i.e. the number of
+// single-character insertions, deletions, or substitutions needed to turn one
+// into the other. Characters are compared as runes rather than bytes.
+func Distance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min3(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(rb)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
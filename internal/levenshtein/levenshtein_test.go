@@ -0,0 +1,24 @@
+package levenshtein
+
+import "testing"
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"", "abc", 3},
+		{"kitten", "sitting", 3},
+		{"requests", "request", 1},
+		{"héllo", "hello", 1},
+		{"日本", "日本語", 1},
+	}
+
+	for _, tt := range tests {
+		if d := Distance(tt.a, tt.b); d != tt.expected {
+			t.Errorf("Distance(%q, %q) expected %d but got %d", tt.a, tt.b, tt.expected, d)
+		}
+	}
+}
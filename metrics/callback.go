@@ -0,0 +1,136 @@
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// GaugeScheduler polls registered gauge callbacks on an interval and emits
+// their values, for gauges like the current queue length or pool size which
+// would otherwise need to be pushed manually from a ticker:
+//
+//   scheduler := metrics.NewGaugeScheduler(client, 10*time.Second)
+//   defer scheduler.Close()
+//
+//   unregister := scheduler.Register("queue.length", map[string]string{
+//     "queue": "jobs",
+//   }, func() float64 {
+//     return float64(queue.Len())
+//   })
+//
+// In tests, pass an interval of zero to disable automatic polling and call
+// `Poll` to emit the current values deterministically:
+//
+//   recorder := metrics.NewRecorderClient().WithTest(t)
+//   scheduler := metrics.NewGaugeScheduler(recorder, 0)
+//   scheduler.Register("queue.length", nil, func() float64 {
+//     return float64(queue.Len())
+//   })
+//   scheduler.Poll()
+//   recorder.Expect("queue.length").Value(0)
+type GaugeScheduler struct {
+	client Client
+
+	lock   sync.Mutex
+	gauges map[int]*callbackGauge
+	nextID int
+	done   chan struct{}
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// callbackGauge is a registered gauge callback.
+type callbackGauge struct {
+	id       int
+	gauge    *Gauge
+	callback func() float64
+}
+
+// NewGaugeScheduler creates a new scheduler which polls registered callbacks
+// every `interval` until closed. An interval of zero or less disables
+// automatic polling.
+func NewGaugeScheduler(client Client, interval time.Duration) *GaugeScheduler {
+	s := &GaugeScheduler{
+		client: client,
+		gauges: make(map[int]*callbackGauge),
+		done:   make(chan struct{}),
+	}
+
+	if interval > 0 {
+		s.wg.Add(1)
+		go s.run(interval)
+	}
+
+	return s
+}
+
+func (s *GaugeScheduler) run(interval time.Duration) {
+	defer s.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.Poll()
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// Register adds a gauge callback which is polled until the returned
+// unregister function is called. `tags` may be `nil`.
+func (s *GaugeScheduler) Register(name string, tags map[string]string, callback func() float64) (unregister func()) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	id := s.nextID
+	s.nextID++
+	s.gauges[id] = &callbackGauge{
+		id:       id,
+		gauge:    NewGauge(s.client, name, tags),
+		callback: callback,
+	}
+
+	return func() {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		delete(s.gauges, id)
+	}
+}
+
+// Poll calls each registered callback and emits its value, in the order the
+// callbacks were registered. Callbacks are called without holding any locks,
+// so they may register or unregister gauges.
+func (s *GaugeScheduler) Poll() {
+	s.lock.Lock()
+	gauges := make([]*callbackGauge, 0, len(s.gauges))
+	for _, g := range s.gauges {
+		gauges = append(gauges, g)
+	}
+	s.lock.Unlock()
+
+	sort.Slice(gauges, func(i, j int) bool {
+		return gauges[i].id < gauges[j].id
+	})
+
+	for _, g := range gauges {
+		g.gauge.Set(g.callback())
+	}
+}
+
+// Close stops automatic polling and waits for any in-progress poll to
+// finish. It does not close the underlying client.
+func (s *GaugeScheduler) Close() error {
+	s.lock.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.done)
+	}
+	s.lock.Unlock()
+
+	s.wg.Wait()
+	return nil
+}
@@ -0,0 +1,94 @@
+package metrics_test
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/istreamlabs/go-metrics/metrics"
+)
+
+func TestGaugeSchedulerPoll(t *testing.T) {
+	recorder := metrics.NewRecorderClient().WithTest(t)
+	scheduler := metrics.NewGaugeScheduler(recorder, 0)
+	defer scheduler.Close()
+
+	length := 3
+	scheduler.Register("queue.length", map[string]string{"queue": "jobs"}, func() float64 {
+		return float64(length)
+	})
+	unregister := scheduler.Register("pool.size", nil, func() float64 {
+		return 10
+	})
+
+	scheduler.Poll()
+	length = 5
+	unregister()
+	unregister()
+	scheduler.Poll()
+
+	recorder.Expect("queue.length").Tag("queue", "jobs").Value(3)
+	recorder.Expect("queue.length").Tag("queue", "jobs").Value(5)
+	recorder.Expect("pool.size").Value(10)
+	if recorder.Length() != 3 {
+		t.Fatalf("Expected 3 calls but got %d", recorder.Length())
+	}
+}
+
+func TestGaugeSchedulerRegisterDuringPoll(t *testing.T) {
+	recorder := metrics.NewRecorderClient().WithTest(t)
+	scheduler := metrics.NewGaugeScheduler(recorder, 0)
+
+	var unregister func()
+	unregister = scheduler.Register("once", nil, func() float64 {
+		unregister()
+		return 1
+	})
+
+	scheduler.Poll()
+	scheduler.Poll()
+	if recorder.Length() != 1 {
+		t.Fatalf("Expected 1 call but got %d", recorder.Length())
+	}
+}
+
+func TestGaugeSchedulerInterval(t *testing.T) {
+	recorder := metrics.NewRecorderClient().WithTest(t)
+
+	calls := make(chan metrics.Call, 100)
+	unsubscribe := recorder.SubscribeChan(calls, metrics.Match().Contains("queue.length"))
+	defer unsubscribe()
+
+	scheduler := metrics.NewGaugeScheduler(recorder, time.Millisecond)
+	var closed int32
+	scheduler.Register("queue.length", nil, func() float64 {
+		if atomic.LoadInt32(&closed) == 1 {
+			t.Error("Expected no polls after closing")
+		}
+		return 1
+	})
+	for i := 0; i < 2; i++ {
+		select {
+		case <-calls:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for poll")
+		}
+	}
+
+	if err := scheduler.Close(); err != nil {
+		t.Fatal(err)
+	}
+	atomic.StoreInt32(&closed, 1)
+	scheduler.Close()
+
+	// Close waits for any in-progress poll, so after draining the earlier
+	// polls the channel stays empty.
+	for len(calls) > 0 {
+		<-calls
+	}
+	select {
+	case <-calls:
+		t.Fatal("Expected no polls after closing")
+	case <-time.After(10 * time.Millisecond):
+	}
+}
@@ -0,0 +1,64 @@
+package metrics
+
+import (
+	"context"
+	"time"
+)
+
+// contextKey is the key for a client stored in a context.
+type contextKey struct{}
+
+// NewContext returns a copy of the context carrying `client`. Retrieve it
+// later via `FromContext`, e.g. in request handlers:
+//
+//   ctx := metrics.NewContext(r.Context(), client)
+//   handler.ServeHTTP(w, r.WithContext(ctx))
+func NewContext(ctx context.Context, client Client) context.Context {
+	return context.WithValue(ctx, contextKey{}, client)
+}
+
+// FromContext returns the client carried by the context, or a `NullClient`
+// if there is none so that callers can always emit metrics.
+func FromContext(ctx context.Context) Client {
+	if client, ok := ctx.Value(contextKey{}).(Client); ok {
+		return client
+	}
+	return NewNullClient()
+}
+
+// ContextWithTags returns a copy of the context whose client has additional
+// tags, building on `WithTags`. This lets middleware add request-scoped tags
+// like a tenant or endpoint which all downstream metrics will include:
+//
+//   ctx = metrics.ContextWithTags(ctx, map[string]string{
+//     "tenant": tenant,
+//   })
+//
+//   // Later, somewhere downstream:
+//   metrics.FromContext(ctx).Incr("jobs.created")
+func ContextWithTags(ctx context.Context, tags map[string]string) context.Context {
+	return NewContext(ctx, FromContext(ctx).WithTags(tags))
+}
+
+// Instrument calls `f` and emits a timing metric named `name` with the
+// duration of the call, using the client and tags from the context. The
+// metric is tagged with `status:success` or `status:error` depending on
+// whether `f` returns an error, which is passed through.
+//
+//   err := metrics.Instrument(ctx, "db.query", func(ctx context.Context) error {
+//     return db.QueryRowContext(ctx, query).Scan(&result)
+//   })
+func Instrument(ctx context.Context, name string, f func(ctx context.Context) error) error {
+	start := time.Now()
+	err := f(ctx)
+
+	status := "success"
+	if err != nil {
+		status = "error"
+	}
+	FromContext(ctx).WithTags(map[string]string{
+		"status": status,
+	}).Timing(name, time.Since(start))
+
+	return err
+}
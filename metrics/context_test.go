@@ -0,0 +1,66 @@
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/istreamlabs/go-metrics/metrics"
+)
+
+func ExampleContextWithTags() {
+	ctx := metrics.NewContext(context.Background(), metrics.NewLoggerClient(nil))
+	ctx = metrics.ContextWithTags(ctx, map[string]string{"tenant": "acme"})
+
+	metrics.FromContext(ctx).Incr("jobs.created")
+	// Output: Count jobs.created:1 map[tenant:acme]
+}
+
+func TestContextFallback(t *testing.T) {
+	if _, ok := metrics.FromContext(context.Background()).(*metrics.NullClient); !ok {
+		t.Fatal("Expected a NullClient fallback")
+	}
+
+	// Adding tags without a client is safe.
+	ctx := metrics.ContextWithTags(context.Background(), map[string]string{"a": "b"})
+	metrics.FromContext(ctx).Incr("ignored")
+}
+
+func TestContextWithTags(t *testing.T) {
+	recorder := metrics.NewRecorderClient().WithTest(t)
+	ctx := metrics.NewContext(context.Background(), recorder.WithTags(map[string]string{"env": "test"}))
+
+	tenant := metrics.ContextWithTags(ctx, map[string]string{"tenant": "acme"})
+	endpoint := metrics.ContextWithTags(tenant, map[string]string{"endpoint": "/jobs", "tenant": "other"})
+
+	metrics.FromContext(ctx).Incr("base")
+	metrics.FromContext(tenant).Incr("tenant")
+	metrics.FromContext(endpoint).Incr("endpoint")
+
+	recorder.Expect("base").Tags(map[string]string{"env": "test"}).NoTagName("tenant")
+	recorder.Expect("tenant").Tags(map[string]string{"env": "test", "tenant": "acme"})
+	recorder.Expect("endpoint").Tags(map[string]string{"env": "test", "tenant": "other", "endpoint": "/jobs"})
+}
+
+func TestInstrument(t *testing.T) {
+	recorder := metrics.NewRecorderClient().WithTest(t)
+	ctx := metrics.ContextWithTags(metrics.NewContext(context.Background(), recorder), map[string]string{"tenant": "acme"})
+
+	if err := metrics.Instrument(ctx, "work", func(ctx context.Context) error {
+		metrics.FromContext(ctx).Incr("inner")
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	failure := errors.New("failed")
+	if err := metrics.Instrument(ctx, "work", func(ctx context.Context) error {
+		return failure
+	}); err != failure {
+		t.Fatalf("Expected error to be passed through but got %v", err)
+	}
+
+	recorder.Expect("inner").Tag("tenant", "acme")
+	recorder.Expect("work").Tags(map[string]string{"tenant": "acme", "status": "success"})
+	recorder.Expect("work").Tags(map[string]string{"tenant": "acme", "status": "error"})
+}
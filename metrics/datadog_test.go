@@ -35,8 +35,8 @@ func ExampleDataDogClient() {
 func TestDataDogClient(t *testing.T) {
 	// This connects to an address that's probably not running anything. The
 	// stats essentially go into `/dev/null`. Right now the only thing this
-	// ensures is that the functions can be called without crashing.
-	// TODO: In the future, we should use a statsd mock here.
+	// ensures is that the functions can be called without crashing. See
+	// `TestDogStatsDServerUDP` for tests of what is sent over the wire.
 	var datadog metrics.Client = metrics.NewDataDogClient("127.0.0.1:8126", "testing", metrics.WithoutTelemetry())
 
 	datadog.Incr("one")
@@ -0,0 +1,434 @@
+package metrics
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-go/v5/statsd"
+)
+
+// Datagram kinds returned by `ParseDogStatsD`.
+const (
+	DatagramMetric       = "metric"
+	DatagramEvent        = "event"
+	DatagramServiceCheck = "service_check"
+)
+
+// metricTypes maps DogStatsD metric types to the client method names used by
+// `MetricCall.Type`.
+var metricTypes = map[string]string{
+	"c":  "Count",
+	"g":  "Gauge",
+	"ms": "Timing",
+	"h":  "Histogram",
+	"d":  "Distribution",
+	"s":  "Set",
+}
+
+// Datagram is a single parsed DogStatsD metric, event, or service check.
+type Datagram struct {
+	// Kind is one of `DatagramMetric`, `DatagramEvent`, or
+	// `DatagramServiceCheck`.
+	Kind string
+
+	// Name, Type, Values, and Rate are set for metrics. Type is the client
+	// method name, e.g. `Count` or `Timing`, and a single datagram may contain
+	// multiple values. Timing values are in milliseconds.
+	Name   string
+	Type   string
+	Values []float64
+	Rate   float64
+
+	// Member is set instead of Values for metrics of type `Set`, whose
+	// values may be any string, e.g. a user name.
+	Member string
+
+	// Tags are the `key:value` tags of the metric, event, or service check.
+	Tags []string
+
+	// Event is set for events.
+	Event *statsd.Event
+
+	// ServiceCheck is set for service checks.
+	ServiceCheck *statsd.ServiceCheck
+}
+
+// ParseDogStatsD parses a DogStatsD packet, which may contain multiple
+// newline-separated datagrams. Lines which cannot be parsed are skipped and
+// the first error encountered is returned along with all valid datagrams.
+func ParseDogStatsD(packet []byte) ([]Datagram, error) {
+	var datagrams []Datagram
+	var firstErr error
+
+	for _, line := range strings.Split(string(packet), "\n") {
+		if line == "" {
+			continue
+		}
+		d, err := parseDatagram(line)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		datagrams = append(datagrams, d)
+	}
+
+	return datagrams, firstErr
+}
+
+// parseDatagram parses a single DogStatsD line.
+func parseDatagram(line string) (Datagram, error) {
+	switch {
+	case strings.HasPrefix(line, "_e{"):
+		return parseEvent(line)
+	case strings.HasPrefix(line, "_sc|"):
+		return parseServiceCheck(line)
+	}
+	return parseMetric(line)
+}
+
+// parseMetric parses `NAME:VALUE[:VALUE...]|TYPE[|@RATE][|#TAGS]...`.
+func parseMetric(line string) (Datagram, error) {
+	fields := strings.Split(line, "|")
+	if len(fields) < 2 {
+		return Datagram{}, fmt.Errorf("invalid metric '%s': missing type", line)
+	}
+
+	colon := strings.IndexByte(fields[0], ':')
+	if colon < 1 {
+		return Datagram{}, fmt.Errorf("invalid metric '%s': missing name or value", line)
+	}
+
+	t, ok := metricTypes[fields[1]]
+	if !ok {
+		return Datagram{}, fmt.Errorf("invalid metric '%s': unknown type '%s'", line, fields[1])
+	}
+
+	d := Datagram{
+		Kind: DatagramMetric,
+		Name: fields[0][:colon],
+		Type: t,
+		Rate: 1.0,
+	}
+
+	if t == "Set" {
+		d.Member = fields[0][colon+1:]
+	} else {
+		for _, raw := range strings.Split(fields[0][colon+1:], ":") {
+			v, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				return Datagram{}, fmt.Errorf("invalid metric '%s': %v", line, err)
+			}
+			d.Values = append(d.Values, v)
+		}
+	}
+
+	for _, field := range fields[2:] {
+		switch {
+		case strings.HasPrefix(field, "@"):
+			rate, err := strconv.ParseFloat(field[1:], 64)
+			if err != nil {
+				return Datagram{}, fmt.Errorf("invalid metric '%s': %v", line, err)
+			}
+			d.Rate = rate
+		case strings.HasPrefix(field, "#"):
+			d.Tags = parseTags(field[1:])
+		}
+	}
+
+	return d, nil
+}
+
+// parseEvent parses `_e{TITLE_LEN,TEXT_LEN}:TITLE|TEXT[|d:TS][|h:HOST]...`.
+func parseEvent(line string) (Datagram, error) {
+	end := strings.Index(line, "}:")
+	if end < 0 {
+		return Datagram{}, fmt.Errorf("invalid event '%s': missing header", line)
+	}
+
+	lengths := strings.Split(line[3:end], ",")
+	if len(lengths) != 2 {
+		return Datagram{}, fmt.Errorf("invalid event '%s': invalid header", line)
+	}
+	titleLen, err1 := strconv.Atoi(lengths[0])
+	textLen, err2 := strconv.Atoi(lengths[1])
+	body := line[end+2:]
+	if err1 != nil || err2 != nil || titleLen < 0 || textLen < 0 || len(body) < titleLen+1+textLen {
+		return Datagram{}, fmt.Errorf("invalid event '%s': invalid lengths", line)
+	}
+
+	e := &statsd.Event{
+		Title: body[:titleLen],
+		Text:  strings.Replace(body[titleLen+1:titleLen+1+textLen], "\\n", "\n", -1),
+	}
+	d := Datagram{Kind: DatagramEvent, Event: e}
+
+	for _, field := range strings.Split(body[titleLen+1+textLen:], "|") {
+		switch {
+		case strings.HasPrefix(field, "d:"):
+			ts, err := strconv.ParseInt(field[2:], 10, 64)
+			if err != nil {
+				return Datagram{}, fmt.Errorf("invalid event '%s': %v", line, err)
+			}
+			e.Timestamp = time.Unix(ts, 0)
+		case strings.HasPrefix(field, "h:"):
+			e.Hostname = field[2:]
+		case strings.HasPrefix(field, "k:"):
+			e.AggregationKey = field[2:]
+		case strings.HasPrefix(field, "p:"):
+			e.Priority = statsd.EventPriority(field[2:])
+		case strings.HasPrefix(field, "s:"):
+			e.SourceTypeName = field[2:]
+		case strings.HasPrefix(field, "t:"):
+			e.AlertType = statsd.EventAlertType(field[2:])
+		case strings.HasPrefix(field, "#"):
+			d.Tags = parseTags(field[1:])
+			e.Tags = d.Tags
+		}
+	}
+
+	return d, nil
+}
+
+// parseServiceCheck parses `_sc|NAME|STATUS[|d:TS][|h:HOST][|#TAGS][|m:MSG]`.
+func parseServiceCheck(line string) (Datagram, error) {
+	fields := strings.Split(line, "|")
+	if len(fields) < 3 {
+		return Datagram{}, fmt.Errorf("invalid service check '%s': missing name or status", line)
+	}
+
+	status, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return Datagram{}, fmt.Errorf("invalid service check '%s': %v", line, err)
+	}
+
+	sc := &statsd.ServiceCheck{
+		Name:   fields[1],
+		Status: statsd.ServiceCheckStatus(status),
+	}
+	d := Datagram{Kind: DatagramServiceCheck, Name: sc.Name, ServiceCheck: sc}
+
+	for _, field := range fields[3:] {
+		switch {
+		case strings.HasPrefix(field, "d:"):
+			ts, err := strconv.ParseInt(field[2:], 10, 64)
+			if err != nil {
+				return Datagram{}, fmt.Errorf("invalid service check '%s': %v", line, err)
+			}
+			sc.Timestamp = time.Unix(ts, 0)
+		case strings.HasPrefix(field, "h:"):
+			sc.Hostname = field[2:]
+		case strings.HasPrefix(field, "m:"):
+			msg := strings.Replace(field[2:], "\\n", "\n", -1)
+			sc.Message = strings.Replace(msg, "m\\:", "m:", -1)
+		case strings.HasPrefix(field, "#"):
+			d.Tags = parseTags(field[1:])
+			sc.Tags = d.Tags
+		}
+	}
+
+	return d, nil
+}
+
+// parseTags splits a comma-separated tag list.
+func parseTags(raw string) []string {
+	if raw == "" {
+		return nil
+	}
+	return strings.Split(raw, ",")
+}
+
+// tagsToMap converts `key:value` tags into a map. Tags without a value are
+// stored with an empty value.
+func tagsToMap(tags []string) map[string]string {
+	tagMap := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		if i := strings.IndexByte(tag, ':'); i >= 0 {
+			tagMap[tag[:i]] = tag[i+1:]
+		} else {
+			tagMap[tag] = ""
+		}
+	}
+	return tagMap
+}
+
+// Calls converts the datagram into recorder calls. Metrics produce one call
+// per value, with timings converted from milliseconds so they can be queried
+// with `time.Duration` values like calls from `RecorderClient.Timing`.
+// Service checks produce a metric call with the type `ServiceCheck` whose
+// value is the check status. Sets produce a single call with a value of `1`
+// per member seen; the member itself is only available on the datagram.
+func (d Datagram) Calls() []Call {
+	now := time.Now()
+	switch d.Kind {
+	case DatagramEvent:
+		return []Call{&EventCall{
+			Event:  copyEvent(d.Event),
+			TagMap: tagsToMap(d.Tags),
+			Time:   now,
+		}}
+	case DatagramServiceCheck:
+		return []Call{&MetricCall{
+			Type:   "ServiceCheck",
+			Name:   d.Name,
+			Value:  float64(d.ServiceCheck.Status),
+			Rate:   1.0,
+			TagMap: tagsToMap(d.Tags),
+			Time:   now,
+		}}
+	}
+
+	values := d.Values
+	if d.Type == "Set" {
+		values = []float64{1}
+	}
+
+	calls := make([]Call, 0, len(values))
+	for _, v := range values {
+		if d.Type == "Timing" {
+			v = float64(time.Duration(v * float64(time.Millisecond)))
+		}
+		calls = append(calls, &MetricCall{
+			Type:   d.Type,
+			Name:   d.Name,
+			Value:  v,
+			Rate:   d.Rate,
+			TagMap: tagsToMap(d.Tags),
+			Time:   now,
+		})
+	}
+	return calls
+}
+
+// DogStatsDServer is a local DogStatsD receiver which parses incoming
+// datagrams and records them into a `RecorderClient`. It allows end-to-end
+// tests of what a `DataDogClient` sends over the wire:
+//
+//   func TestWire(t *testing.T) {
+//     recorder := metrics.NewRecorderClient().WithTest(t)
+//     server, err := metrics.ListenDogStatsD("udp", "127.0.0.1:0", recorder)
+//     if err != nil {
+//       t.Fatal(err)
+//     }
+//     defer server.Close()
+//
+//     client := metrics.NewDataDogClient(server.Address(), "myprefix", metrics.WithoutTelemetry())
+//     client.Incr("requests.count")
+//     client.Close()
+//
+//     server.WaitForCalls(1, time.Second)
+//     recorder.Expect("myprefix.requests.count").Value(1)
+//   }
+type DogStatsDServer struct {
+	recorder *RecorderClient
+	conn     net.PacketConn
+	address  string
+	socket   string
+	done     chan struct{}
+
+	mutex    sync.Mutex
+	received int
+	errors   []error
+}
+
+// ListenDogStatsD starts a DogStatsD server on the given `network`, which is
+// either `udp` or `unixgram`. For UDP, use a port of `0` to pick a free port.
+// For Unix sockets, `address` is the socket path.
+func ListenDogStatsD(network, address string, recorder *RecorderClient) (*DogStatsDServer, error) {
+	conn, err := net.ListenPacket(network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &DogStatsDServer{
+		recorder: recorder,
+		conn:     conn,
+		address:  conn.LocalAddr().String(),
+		done:     make(chan struct{}),
+	}
+	if network == "unixgram" {
+		s.socket = address
+		s.address = statsd.UnixAddressPrefix + address
+	}
+
+	go s.serve()
+	return s, nil
+}
+
+// serve reads packets until the connection is closed.
+func (s *DogStatsDServer) serve() {
+	defer close(s.done)
+	buf := make([]byte, 65535)
+	for {
+		n, _, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+
+		datagrams, err := ParseDogStatsD(buf[:n])
+		for _, d := range datagrams {
+			for _, call := range d.Calls() {
+				s.recorder.record(call)
+			}
+		}
+
+		s.mutex.Lock()
+		s.received += len(datagrams)
+		if err != nil {
+			s.errors = append(s.errors, err)
+		}
+		s.mutex.Unlock()
+	}
+}
+
+// Address returns the address to pass to `NewDataDogClient` to send metrics
+// to this server. Unix sockets are prefixed with `unix://`.
+func (s *DogStatsDServer) Address() string {
+	return s.address
+}
+
+// Received returns the number of datagrams received so far.
+func (s *DogStatsDServer) Received() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.received
+}
+
+// Errors returns any errors encountered while parsing datagrams.
+func (s *DogStatsDServer) Errors() []error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]error(nil), s.errors...)
+}
+
+// WaitForCalls waits until the recorder contains at least `count` calls,
+// returning an error on timeout. Since datagrams are delivered
+// asynchronously, use this after closing or flushing the sending client.
+func (s *DogStatsDServer) WaitForCalls(count int, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for s.recorder.Length() < count {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out waiting for %d calls, have %d", count, s.recorder.Length())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return nil
+}
+
+// Close stops the server and removes its Unix socket, if any.
+func (s *DogStatsDServer) Close() error {
+	err := s.conn.Close()
+	<-s.done
+	if s.socket != "" {
+		if rmErr := os.Remove(s.socket); rmErr != nil && !os.IsNotExist(rmErr) && err == nil {
+			err = rmErr
+		}
+	}
+	return err
+}
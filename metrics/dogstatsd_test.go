@@ -0,0 +1,137 @@
+package metrics_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-go/v5/statsd"
+	"github.com/istreamlabs/go-metrics/metrics"
+)
+
+func TestParseDogStatsD(t *testing.T) {
+	packet := "requests:1|c|@0.5|#env:prod,status:200\n" +
+		"latency:1.5:2|ms\n" +
+		"_e{6,10}:deploy|v1\\nfailed|d:1600000000|h:host-1|k:key|p:low|s:ci|t:error|#team:video\n" +
+		"_sc|db.health|2|d:1600000000|h:host-1|#env:prod|m:down\n" +
+		"users:alice|s|#env:prod\n" +
+		"bad\n"
+
+	datagrams, err := metrics.ParseDogStatsD([]byte(packet))
+	if err == nil {
+		t.Fatal("Expected an error for the invalid line")
+	}
+	if len(datagrams) != 5 {
+		t.Fatalf("Expected 5 datagrams but got %d", len(datagrams))
+	}
+
+	ExpectEqual(t, metrics.Datagram{
+		Kind:   metrics.DatagramMetric,
+		Name:   "users",
+		Type:   "Set",
+		Member: "alice",
+		Rate:   1.0,
+		Tags:   []string{"env:prod"},
+	}, datagrams[4])
+	set := datagrams[4].Calls()[0].(*metrics.MetricCall)
+	ExpectEqual(t, "Set", set.Type)
+	ExpectEqual(t, 1.0, set.Value)
+
+	ExpectEqual(t, metrics.Datagram{
+		Kind:   metrics.DatagramMetric,
+		Name:   "requests",
+		Type:   "Count",
+		Values: []float64{1},
+		Rate:   0.5,
+		Tags:   []string{"env:prod", "status:200"},
+	}, datagrams[0])
+
+	ExpectEqual(t, "Timing", datagrams[1].Type)
+	ExpectEqual(t, []float64{1.5, 2}, datagrams[1].Values)
+	ExpectEqual(t, 1.0, datagrams[1].Rate)
+
+	ExpectEqual(t, &statsd.Event{
+		Title:          "deploy",
+		Text:           "v1\nfailed",
+		Timestamp:      time.Unix(1600000000, 0),
+		Hostname:       "host-1",
+		AggregationKey: "key",
+		Priority:       statsd.Low,
+		SourceTypeName: "ci",
+		AlertType:      statsd.Error,
+		Tags:           []string{"team:video"},
+	}, datagrams[2].Event)
+
+	ExpectEqual(t, &statsd.ServiceCheck{
+		Name:      "db.health",
+		Status:    statsd.Critical,
+		Timestamp: time.Unix(1600000000, 0),
+		Hostname:  "host-1",
+		Message:   "down",
+		Tags:      []string{"env:prod"},
+	}, datagrams[3].ServiceCheck)
+}
+
+// sendAll emits one of each type of call through a DataDog client.
+func sendAll(t *testing.T, address string) {
+	client := metrics.NewDataDogClient(address, "test", metrics.WithoutTelemetry())
+	tagged := client.WithTags(map[string]string{"env": "prod"})
+	tagged.Incr("requests")
+	tagged.Gauge("queue.length", 3)
+	client.Timing("latency", 2*time.Second)
+	client.Histogram("histo", 1.5)
+	client.Distribution("distro", 999)
+	tagged.Event(&statsd.Event{Title: "deploy", Text: "v1", AlertType: statsd.Error})
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// expectAll asserts that the calls from `sendAll` were received.
+func expectAll(t *testing.T, recorder *metrics.RecorderClient, server *metrics.DogStatsDServer) {
+	if err := server.WaitForCalls(6, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if errs := server.Errors(); len(errs) > 0 {
+		t.Fatalf("Unexpected parse errors: %v", errs)
+	}
+
+	recorder.Expect("test.requests").Value(1).Tags(map[string]string{"env": "prod"})
+	recorder.Expect("test.queue.length").Value(3).Tag("env", "prod")
+	recorder.Expect("test.latency").Value(2 * time.Second)
+	recorder.Expect("test.histo").Value(1.5)
+	recorder.Expect("test.distro").Value(999)
+	recorder.Expect("deploy").Text("v1").AlertType(statsd.Error).Tag("env", "prod")
+}
+
+func TestDogStatsDServerUDP(t *testing.T) {
+	recorder := metrics.NewRecorderClient().WithTest(t)
+	server, err := metrics.ListenDogStatsD("udp", "127.0.0.1:0", recorder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	sendAll(t, server.Address())
+	expectAll(t, recorder, server)
+}
+
+func TestDogStatsDServerUDS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dogstatsd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	recorder := metrics.NewRecorderClient().WithTest(t)
+	server, err := metrics.ListenDogStatsD("unixgram", filepath.Join(dir, "dsd.socket"), recorder)
+	if err != nil {
+		t.Skipf("Unix datagram sockets not supported: %v", err)
+	}
+	defer server.Close()
+
+	sendAll(t, server.Address())
+	expectAll(t, recorder, server)
+}
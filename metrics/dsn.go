@@ -0,0 +1,209 @@
+package metrics
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/DataDog/datadog-go/v5/statsd"
+	"github.com/mattn/go-isatty"
+)
+
+// Environment variables used by `NewClientFromEnv`. These are the same
+// variables used by the DataDog agent and tracing libraries.
+const (
+	EnvAgentHost     = "DD_AGENT_HOST"
+	EnvDogStatsDPort = "DD_DOGSTATSD_PORT"
+	EnvEntityID      = "DD_ENTITY_ID"
+)
+
+// DefaultDogStatsDPort is the port used when `DD_DOGSTATSD_PORT` is not set.
+const DefaultDogStatsDPort = "8125"
+
+// NewClientFromURL creates a client from a URL, which lets the metrics backend
+// be picked via configuration rather than code. The following schemes are
+// supported:
+//
+//   datadog://127.0.0.1:8125?namespace=myprefix&telemetry=false
+//   unix:///var/run/datadog/dsd.socket?namespace=myprefix
+//   log://stdout?color=auto
+//   null://
+//   recorder://
+//
+// The `datadog` and `unix` schemes create a `DataDogClient` sending over UDP
+// or a Unix domain socket, and accept `namespace` and `telemetry` parameters.
+// A `datadog` URL without a host uses the `DD_AGENT_HOST` and
+// `DD_DOGSTATSD_PORT` environment variables.
+//
+// The `log` scheme creates a `LoggerClient` writing to `stdout` (the default)
+// or `stderr`, and accepts a `color` parameter of `auto` (the default), `true`,
+// or `false`.
+//
+// Unlike `NewDataDogClient`, invalid configuration returns an error rather
+// than panicking.
+func NewClientFromURL(rawurl string) (Client, error) {
+	if rawurl == "" {
+		return nil, fmt.Errorf("invalid metrics URL: empty")
+	}
+
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid metrics URL '%s': %v", rawurl, err)
+	}
+
+	var client Client
+	switch u.Scheme {
+	case "datadog":
+		if u.Path != "" && u.Path != "/" {
+			return nil, fmt.Errorf("invalid metrics URL '%s': unexpected path", rawurl)
+		}
+		client, err = newDataDogClientFromURL(u.Host, u.Query())
+	case "unix":
+		if u.Path == "" {
+			return nil, fmt.Errorf("invalid metrics URL '%s': missing socket path", rawurl)
+		}
+		client, err = newDataDogClientFromURL(statsd.UnixAddressPrefix+u.Path, u.Query())
+	case "log":
+		client, err = newLoggerClientFromURL(u.Host, u.Query())
+	case "null":
+		client, err = NewNullClient(), checkParams(u.Query())
+	case "recorder":
+		client, err = NewRecorderClient(), checkParams(u.Query())
+	default:
+		return nil, fmt.Errorf("invalid metrics URL '%s': unknown scheme '%s'", rawurl, u.Scheme)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid metrics URL '%s': %v", rawurl, err)
+	}
+	return client, nil
+}
+
+// NewClientFromEnv creates a `DataDogClient` with the metrics prefix of
+// `namespace` when the `DD_AGENT_HOST` environment variable is set, otherwise
+// a `LoggerClient` writing to stdout for local development. This replaces
+// hand-written environment checks:
+//
+//   client, err := metrics.NewClientFromEnv("myprefix")
+//   if err != nil {
+//     panic(err)
+//   }
+//   defer client.Close()
+//
+// The agent host may be a hostname, an IP address, or a `unix://` socket
+// path. The port defaults to `8125` unless `DD_DOGSTATSD_PORT` is set. When
+// `DD_ENTITY_ID` is set, e.g. to the pod UID via the Kubernetes downward API,
+// it is sent as the `dd.internal.entity_id` tag for origin detection.
+func NewClientFromEnv(namespace string) (Client, error) {
+	host := os.Getenv(EnvAgentHost)
+	if host == "" {
+		return NewLoggerClient(nil), nil
+	}
+
+	address := host
+	if !strings.HasPrefix(host, statsd.UnixAddressPrefix) {
+		port := os.Getenv(EnvDogStatsDPort)
+		if port == "" {
+			port = DefaultDogStatsDPort
+		}
+		if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+			return nil, fmt.Errorf("invalid %s '%s': %v", EnvDogStatsDPort, port, err)
+		}
+		address = net.JoinHostPort(host, port)
+	}
+
+	return newDataDogClient(address, namespace, true)
+}
+
+// newDataDogClientFromURL creates a DataDog client from URL parameters.
+func newDataDogClientFromURL(address string, params url.Values) (Client, error) {
+	namespace := params.Get("namespace")
+	telemetry := true
+	if v := params.Get("telemetry"); v != "" {
+		var err error
+		if telemetry, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("invalid telemetry '%s'", v)
+		}
+	}
+
+	if err := checkParams(params, "namespace", "telemetry"); err != nil {
+		return nil, err
+	}
+
+	return newDataDogClient(address, namespace, telemetry)
+}
+
+// newDataDogClient creates a DataDog client, returning any statsd errors.
+// The statsd client adds the `DD_ENTITY_ID` tag itself when set.
+func newDataDogClient(address, namespace string, telemetry bool) (Client, error) {
+	var opts []statsd.Option
+	if !telemetry {
+		opts = append(opts, statsd.WithoutTelemetry())
+	}
+	if namespace != "" {
+		opts = append(opts, statsd.WithNamespace(namespace))
+	}
+
+	s, err := statsd.New(address, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return NewDataDogClient("", "", WithStatsd(s)), nil
+}
+
+// newLoggerClientFromURL creates a logger client from a URL host naming the
+// output stream and parameters.
+func newLoggerClientFromURL(output string, params url.Values) (Client, error) {
+	var f *os.File
+	switch output {
+	case "", "stdout":
+		f = os.Stdout
+	case "stderr":
+		f = os.Stderr
+	default:
+		return nil, fmt.Errorf("unknown log output '%s'", output)
+	}
+
+	var colors bool
+	switch v := params.Get("color"); v {
+	case "", "auto":
+		colors = isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+	default:
+		var err error
+		if colors, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("invalid color '%s'", v)
+		}
+	}
+
+	if err := checkParams(params, "color"); err != nil {
+		return nil, err
+	}
+
+	return &LoggerClient{
+		logger: log.New(f, "", 0),
+		colors: colors,
+		rate:   1.0,
+	}, nil
+}
+
+// checkParams returns an error for any parameter not in `allowed`, to catch
+// typos which would otherwise be silently ignored.
+func checkParams(params url.Values, allowed ...string) error {
+	for name := range params {
+		found := false
+		for _, a := range allowed {
+			if name == a {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("unknown parameter '%s'", name)
+		}
+	}
+	return nil
+}
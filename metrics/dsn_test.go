@@ -0,0 +1,211 @@
+package metrics_test
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/istreamlabs/go-metrics/metrics"
+)
+
+func ExampleNewClientFromURL() {
+	// Pick the metrics backend via configuration, e.g. `null://` in tests.
+	client, err := metrics.NewClientFromURL("datadog://127.0.0.1:8125?namespace=myprefix")
+	if err != nil {
+		panic(err)
+	}
+	defer client.Close()
+
+	client.Incr("requests.count")
+}
+
+// setenv sets environment variables for the duration of a test.
+func setenv(t *testing.T, vars map[string]string) {
+	for name, value := range vars {
+		old, ok := os.LookupEnv(name)
+		if value == "" {
+			os.Unsetenv(name)
+		} else {
+			os.Setenv(name, value)
+		}
+
+		name := name
+		t.Cleanup(func() {
+			if ok {
+				os.Setenv(name, old)
+			} else {
+				os.Unsetenv(name)
+			}
+		})
+	}
+}
+
+func TestNewClientFromURL(t *testing.T) {
+	cases := []struct {
+		url    string
+		client interface{}
+	}{
+		{"datadog://127.0.0.1:8125", &metrics.DataDogClient{}},
+		{"datadog://127.0.0.1:8125?namespace=x&telemetry=false", &metrics.DataDogClient{}},
+		{"unix:///var/run/datadog/dsd.socket", &metrics.DataDogClient{}},
+		{"log://", &metrics.LoggerClient{}},
+		{"log://stdout?color=auto", &metrics.LoggerClient{}},
+		{"log://stderr?color=false", &metrics.LoggerClient{}},
+		{"null://", &metrics.NullClient{}},
+		{"recorder://", &metrics.RecorderClient{}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.url, func(t *testing.T) {
+			client, err := metrics.NewClientFromURL(tc.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer client.Close()
+
+			if reflect.TypeOf(client) != reflect.TypeOf(tc.client) {
+				t.Fatalf("Expected %T but got %T", tc.client, client)
+			}
+		})
+	}
+}
+
+func TestNewClientFromURLErrors(t *testing.T) {
+	cases := map[string]string{
+		"":                                "empty",
+		"statsd://127.0.0.1:8125":         "unknown scheme 'statsd'",
+		"datadog://127.0.0.1:8125/foo":    "unexpected path",
+		"datadog://127.0.0.1?telemetry=x": "invalid telemetry 'x'",
+		"datadog://127.0.0.1?prefix=x":    "unknown parameter 'prefix'",
+		"unix://":                         "missing socket path",
+		"log://file":                      "unknown log output 'file'",
+		"log://stdout?color=maybe":        "invalid color 'maybe'",
+		"null://?namespace=x":             "unknown parameter 'namespace'",
+		"recorder://?foo=bar":             "unknown parameter 'foo'",
+		"%":                               "invalid URL escape",
+	}
+
+	for url, message := range cases {
+		t.Run(url, func(t *testing.T) {
+			client, err := metrics.NewClientFromURL(url)
+			if err == nil {
+				client.Close()
+				t.Fatal("Expected error")
+			}
+			if !strings.Contains(err.Error(), message) {
+				t.Fatalf("Expected error containing '%s' but got '%v'", message, err)
+			}
+		})
+	}
+}
+
+func TestNewClientFromURLSends(t *testing.T) {
+	recorder := metrics.NewRecorderClient().WithTest(t)
+	server, err := metrics.ListenDogStatsD("udp", "127.0.0.1:0", recorder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	client, err := metrics.NewClientFromURL("datadog://" + server.Address() + "?namespace=myprefix&telemetry=false")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Incr("requests.count")
+	client.Close()
+
+	if err := server.WaitForCalls(1, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	recorder.Expect("myprefix.requests.count").Value(1)
+}
+
+func TestNewClientFromURLUnix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dogstatsd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	recorder := metrics.NewRecorderClient().WithTest(t)
+	server, err := metrics.ListenDogStatsD("unixgram", filepath.Join(dir, "dsd.socket"), recorder)
+	if err != nil {
+		t.Skipf("Unix datagram sockets not supported: %v", err)
+	}
+	defer server.Close()
+
+	client, err := metrics.NewClientFromURL(server.Address() + "?telemetry=false")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Incr("requests.count")
+	client.Close()
+
+	if err := server.WaitForCalls(1, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	recorder.Expect("requests.count").Value(1)
+}
+
+func TestNewClientFromEnv(t *testing.T) {
+	recorder := metrics.NewRecorderClient().WithTest(t)
+	server, err := metrics.ListenDogStatsD("udp", "127.0.0.1:0", recorder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	host, port, _ := net.SplitHostPort(server.Address())
+	setenv(t, map[string]string{
+		metrics.EnvAgentHost:     host,
+		metrics.EnvDogStatsDPort: port,
+		metrics.EnvEntityID:      "pod-1234",
+	})
+
+	client, err := metrics.NewClientFromEnv("myprefix")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Incr("requests.count")
+	client.Close()
+
+	if err := server.WaitForCalls(1, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	recorder.
+		Expect("myprefix.requests.count").
+		Value(1).
+		Tag("dd.internal.entity_id", "pod-1234")
+}
+
+func TestNewClientFromEnvLocal(t *testing.T) {
+	setenv(t, map[string]string{
+		metrics.EnvAgentHost: "",
+	})
+
+	client, err := metrics.NewClientFromEnv("myprefix")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	if _, ok := client.(*metrics.LoggerClient); !ok {
+		t.Fatalf("Expected LoggerClient but got %T", client)
+	}
+}
+
+func TestNewClientFromEnvInvalidPort(t *testing.T) {
+	setenv(t, map[string]string{
+		metrics.EnvAgentHost:     "127.0.0.1",
+		metrics.EnvDogStatsDPort: "http",
+	})
+
+	if _, err := metrics.NewClientFromEnv(""); err == nil {
+		t.Fatal("Expected error")
+	}
+}
@@ -0,0 +1,322 @@
+package metrics
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-go/v5/statsd"
+)
+
+// cleaner is implemented by test instances that can register functions to
+// run when the test completes, e.g. `testing.T` in Go 1.14+.
+type cleaner interface {
+	Cleanup(func())
+}
+
+// expectation is a `Query` whose filters are recorded and only evaluated
+// when the recorder is verified. It is returned by `RecorderClient.Want`.
+type expectation struct {
+	recorder *RecorderClient
+	id       string
+	hasID    bool
+	ops      []func(Query) Query
+	reject   bool
+}
+
+// then records a filter operation to run at verification time.
+func (e *expectation) then(op func(Query) Query) Query {
+	e.ops = append(e.ops, op)
+	return e
+}
+
+// eval runs the recorded filter operations against the current calls.
+func (e *expectation) eval() *query {
+	return e.run(e.recorder.newQuery(false))
+}
+
+// run applies the recorded filter operations to a query.
+func (e *expectation) run(q *query) *query {
+	if e.hasID {
+		q.ID(e.id)
+	}
+	for _, op := range e.ops {
+		op(q)
+	}
+	return q
+}
+
+// matches returns whether a single call passes every recorded filter.
+func (e *expectation) matches(call Call) bool {
+	q := e.run(&query{
+		calls:    []Call{call},
+		all:      []Call{call},
+		test:     e.recorder,
+		helper:   noHelper{},
+		minCalls: 1,
+	})
+	return len(q.calls) > 0
+}
+
+// describe returns a user-friendly representation of the recorded filters.
+func (e *expectation) describe() string {
+	q := e.run(&query{helper: noHelper{}})
+	return strings.Trim(q.history, " ")
+}
+
+// Match creates a sub-query for use with `Query.Not` and `Query.Or`. Unlike
+// `Expect`, it matches calls with any ID unless `ID` is chained onto it:
+//
+//   // Tag status is 500 or 503.
+//   recorder.Expect("http.requests").Or(
+//     metrics.Match().Tag("status", "500"),
+//     metrics.Match().Tag("status", "503"),
+//   )
+func Match() Query {
+	return &expectation{}
+}
+
+// toExpectation converts a sub-query into an expectation, panicking if it was
+// not created via `Match` or `RecorderClient.Filter`.
+func toExpectation(q Query) *expectation {
+	e, ok := q.(*expectation)
+	if !ok {
+		panic("Sub-queries must be created with `metrics.Match()` or `recorder.Filter(id)`")
+	}
+	return e
+}
+
+// Reject marks the expectation as one that must not match any calls.
+func (e *expectation) Reject() {
+	e.reject = true
+}
+
+// Accept marks the expectation as one that must match calls. This is the
+// default behavior.
+func (e *expectation) Accept() {
+	e.reject = false
+}
+
+// GetCalls returns the calls currently matching the expectation.
+func (e *expectation) GetCalls() []Call {
+	return e.eval().GetCalls()
+}
+
+// MinTimes sets the minimum required number of calls.
+func (e *expectation) MinTimes(num int) Query {
+	return e.then(func(q Query) Query { return q.MinTimes(num) })
+}
+
+// Contains checks whether the serialized metric contains the given value.
+func (e *expectation) Contains(component string) Query {
+	return e.then(func(q Query) Query { return q.Contains(component) })
+}
+
+// ID expects a metric name or event title.
+func (e *expectation) ID(id string) Query {
+	return e.then(func(q Query) Query { return q.ID(id) })
+}
+
+// Value expects a metric value.
+func (e *expectation) Value(value interface{}) Query {
+	return e.then(func(q Query) Query { return q.Value(value) })
+}
+
+// Text expects an event with the given text content value.
+func (e *expectation) Text(text string) Query {
+	return e.then(func(q Query) Query { return q.Text(text) })
+}
+
+// Tag expects a tag name and value.
+func (e *expectation) Tag(name, value string) Query {
+	return e.then(func(q Query) Query { return q.Tag(name, value) })
+}
+
+// TagName expects a tag name to exist.
+func (e *expectation) TagName(name string) Query {
+	return e.then(func(q Query) Query { return q.TagName(name) })
+}
+
+// TagMatch expects a tag value to match a regular expression.
+func (e *expectation) TagMatch(name, pattern string) Query {
+	return e.then(func(q Query) Query { return q.TagMatch(name, pattern) })
+}
+
+// Tags expects the tag set to be exactly equal to the given map.
+func (e *expectation) Tags(tags map[string]string) Query {
+	return e.then(func(q Query) Query { return q.Tags(tags) })
+}
+
+// OnlyTagNames expects every tag name to be one of the allowed names.
+func (e *expectation) OnlyTagNames(names ...string) Query {
+	return e.then(func(q Query) Query { return q.OnlyTagNames(names...) })
+}
+
+// NoTagName expects a tag name to not exist.
+func (e *expectation) NoTagName(name string) Query {
+	return e.then(func(q Query) Query { return q.NoTagName(name) })
+}
+
+// Rate expects a metric with the given sample rate.
+func (e *expectation) Rate(rate float64) Query {
+	return e.then(func(q Query) Query { return q.Rate(rate) })
+}
+
+// After expects a metric or event emitted after the given time.
+func (e *expectation) After(t time.Time) Query {
+	return e.then(func(q Query) Query { return q.After(t) })
+}
+
+// Before expects a metric or event emitted before the given time.
+func (e *expectation) Before(t time.Time) Query {
+	return e.then(func(q Query) Query { return q.Before(t) })
+}
+
+// Not expects calls which do not match the sub-query.
+func (e *expectation) Not(sub Query) Query {
+	return e.then(func(q Query) Query { return q.Not(sub) })
+}
+
+// Or expects calls which match any of the sub-queries.
+func (e *expectation) Or(subs ...Query) Query {
+	return e.then(func(q Query) Query { return q.Or(subs...) })
+}
+
+// Where expects calls for which the predicate returns true.
+func (e *expectation) Where(description string, pred func(Call) bool) Query {
+	return e.then(func(q Query) Query { return q.Where(description, pred) })
+}
+
+// Priority expects an event with the given priority.
+func (e *expectation) Priority(priority statsd.EventPriority) Query {
+	return e.then(func(q Query) Query { return q.Priority(priority) })
+}
+
+// AlertType expects an event with the given alert type.
+func (e *expectation) AlertType(alertType statsd.EventAlertType) Query {
+	return e.then(func(q Query) Query { return q.AlertType(alertType) })
+}
+
+// AggregationKey expects an event with the given aggregation key.
+func (e *expectation) AggregationKey(key string) Query {
+	return e.then(func(q Query) Query { return q.AggregationKey(key) })
+}
+
+// SourceTypeName expects an event with the given source type name.
+func (e *expectation) SourceTypeName(name string) Query {
+	return e.then(func(q Query) Query { return q.SourceTypeName(name) })
+}
+
+// Hostname expects an event with the given hostname.
+func (e *expectation) Hostname(hostname string) Query {
+	return e.then(func(q Query) Query { return q.Hostname(hostname) })
+}
+
+// Timestamp expects an event with a timestamp within the given range.
+func (e *expectation) Timestamp(from, to time.Time) Query {
+	return e.then(func(q Query) Query { return q.Timestamp(from, to) })
+}
+
+// Want declares an expected metric (by name) or event (by title) that is
+// checked later rather than immediately. Filters chained onto the returned
+// query are recorded and evaluated when `Verify` is called, which happens
+// automatically when the test completes if the attached test supports
+// `Cleanup` (e.g. `testing.T`):
+//
+//   func MyTest(t *testing.T) {
+//     recorder := metrics.NewRecorderClient().WithTest(t)
+//     recorder.Want("my.metric").Value(1).Tag("env", "prod")
+//     recorder.Want("my.error").Reject()
+//
+//     // Run the code under test, no further checks are needed.
+//     doSomething(recorder)
+//   }
+func (c *RecorderClient) Want(id string) Query {
+	e := &expectation{recorder: c, id: id, hasID: true}
+
+	c.callInfo.RWMutex.Lock()
+	defer c.callInfo.RWMutex.Unlock()
+	c.callInfo.Wants = append(c.callInfo.Wants, e)
+	c.registerVerify()
+
+	return e
+}
+
+// registerVerify registers `Verify` to run when the attached test completes,
+// if it supports `Cleanup` and this has not already been done for that test.
+// The caller must hold the call info lock.
+func (c *RecorderClient) registerVerify() {
+	var t interface{} = c.test
+	if c.errorer != nil {
+		t = c.errorer
+	}
+	cl, ok := t.(cleaner)
+	if !ok || !reflect.TypeOf(t).Comparable() || c.callInfo.Registered[t] {
+		return
+	}
+
+	if c.callInfo.Registered == nil {
+		c.callInfo.Registered = make(map[interface{}]bool)
+	}
+	c.callInfo.Registered[t] = true
+	cl.Cleanup(func() {
+		c.callInfo.RWMutex.Lock()
+		delete(c.callInfo.Registered, t)
+		c.callInfo.RWMutex.Unlock()
+		c.Verify()
+	})
+}
+
+// Strict enables strict mode for this recorder and all of its clones. In
+// strict mode, `Verify` additionally fails if any recorded call is not
+// matched by an accepted expectation declared via `Want`. Like `Want`, this
+// registers `Verify` to run when the attached test completes, so unexpected
+// calls fail the test even if no expectations are declared.
+func (c *RecorderClient) Strict() *RecorderClient {
+	c.callInfo.RWMutex.Lock()
+	defer c.callInfo.RWMutex.Unlock()
+	c.callInfo.Strict = true
+	c.registerVerify()
+	return c
+}
+
+// Verify checks every expectation declared via `Want` and fails the attached
+// test for each one that is not met. Verified expectations are cleared, so
+// calling `Verify` explicitly does not cause them to be reported again when
+// the test completes.
+func (c *RecorderClient) Verify() {
+	c.helper().Helper()
+
+	c.callInfo.RWMutex.Lock()
+	wants := c.callInfo.Wants
+	strict := c.callInfo.Strict
+	c.callInfo.Wants = nil
+	c.callInfo.RWMutex.Unlock()
+
+	matched := make(map[Call]bool)
+	for _, e := range wants {
+		// Report through this recorder's test, which may have been attached
+		// after the expectation was declared.
+		q := e.run(c.newQuery(false))
+		if e.reject {
+			q.Reject()
+			continue
+		}
+		q.Accept()
+		for _, call := range q.GetCalls() {
+			matched[call] = true
+		}
+	}
+
+	if strict {
+		var unexpected []string
+		for _, call := range c.callsCopy() {
+			if !matched[call] {
+				unexpected = append(unexpected, call.String())
+			}
+		}
+		if len(unexpected) > 0 {
+			c.Fatalf("Unexpected metrics '%s'.", strings.Join(unexpected, "', '"))
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package metrics
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Counter is a count metric bound to a name and fixed tags. Tags are applied
+// once when the counter is created, so emitting does not allocate a new
+// client or tag slice like `client.WithTags(tags).Incr(name)` does:
+//
+//   requests := metrics.NewCounter(client, "requests.count", map[string]string{
+//     "handler": "users",
+//   })
+//
+//   // Later, on the hot path:
+//   requests.Inc()
+type Counter struct {
+	client Client
+	name   string
+}
+
+// NewCounter creates a new counter. `tags` may be `nil`.
+func NewCounter(client Client, name string, tags map[string]string) *Counter {
+	return &Counter{client: bind(client, tags), name: name}
+}
+
+// Add adds some value to the counter.
+func (c *Counter) Add(value int64) {
+	c.client.Count(c.name, value)
+}
+
+// Inc adds one to the counter.
+func (c *Counter) Inc() {
+	c.client.Incr(c.name)
+}
+
+// Dec subtracts one from the counter.
+func (c *Counter) Dec() {
+	c.client.Decr(c.name)
+}
+
+// Gauge is a gauge metric bound to a name and fixed tags.
+type Gauge struct {
+	client Client
+	name   string
+}
+
+// NewGauge creates a new gauge. `tags` may be `nil`.
+func NewGauge(client Client, name string, tags map[string]string) *Gauge {
+	return &Gauge{client: bind(client, tags), name: name}
+}
+
+// Set sets the gauge value.
+func (g *Gauge) Set(value float64) {
+	g.client.Gauge(g.name, value)
+}
+
+// Timer is a timing metric bound to a name and fixed tags.
+type Timer struct {
+	client Client
+	name   string
+}
+
+// NewTimer creates a new timer. `tags` may be `nil`.
+func NewTimer(client Client, name string, tags map[string]string) *Timer {
+	return &Timer{client: bind(client, tags), name: name}
+}
+
+// Record emits a duration.
+func (t *Timer) Record(value time.Duration) {
+	t.client.Timing(t.name, value)
+}
+
+// Since emits the duration since `start`, e.g. `defer timer.Since(time.Now())`.
+func (t *Timer) Since(start time.Time) {
+	t.client.Timing(t.name, time.Since(start))
+}
+
+// Histogram is a histogram metric bound to a name and fixed tags.
+type Histogram struct {
+	client Client
+	name   string
+}
+
+// NewHistogram creates a new histogram. `tags` may be `nil`.
+func NewHistogram(client Client, name string, tags map[string]string) *Histogram {
+	return &Histogram{client: bind(client, tags), name: name}
+}
+
+// Observe emits a value.
+func (h *Histogram) Observe(value float64) {
+	h.client.Histogram(h.name, value)
+}
+
+// bind returns the client with tags applied, if any.
+func bind(client Client, tags map[string]string) Client {
+	if len(tags) == 0 {
+		return client
+	}
+	return client.WithTags(tags)
+}
+
+// vec caches handles by tag values in declared key order. Lookups of existing
+// handles do not allocate.
+type vec struct {
+	lock   sync.RWMutex
+	client Client
+	name   string
+	keys   []string
+	root   vecNode
+	create func(client Client, name string, tags map[string]string) interface{}
+}
+
+// vecNode is a node in the tree of tag values. The path from the root to a
+// node at depth `len(keys)` holds the values of a single handle.
+type vecNode struct {
+	children map[string]*vecNode
+	handle   interface{}
+}
+
+func newVec(client Client, name string, keys []string, create func(Client, string, map[string]string) interface{}) *vec {
+	return &vec{
+		client: client,
+		name:   name,
+		keys:   append([]string(nil), keys...),
+		create: create,
+	}
+}
+
+// get returns the handle for the given tag values, creating it if needed.
+func (v *vec) get(values []string) interface{} {
+	if len(values) != len(v.keys) {
+		panic(fmt.Sprintf("metric '%s' expects %d tag values %v but got %d", v.name, len(v.keys), v.keys, len(values)))
+	}
+
+	var handle interface{}
+	v.lock.RLock()
+	node := &v.root
+	for _, value := range values {
+		if node = node.children[value]; node == nil {
+			break
+		}
+	}
+	if node != nil {
+		handle = node.handle
+	}
+	v.lock.RUnlock()
+	if handle != nil {
+		return handle
+	}
+
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	node = &v.root
+	for _, value := range values {
+		child := node.children[value]
+		if child == nil {
+			if node.children == nil {
+				node.children = make(map[string]*vecNode)
+			}
+			child = &vecNode{}
+			node.children[value] = child
+		}
+		node = child
+	}
+	if node.handle == nil {
+		tags := make(map[string]string, len(v.keys))
+		for i, key := range v.keys {
+			tags[key] = values[i]
+		}
+		node.handle = v.create(v.client, v.name, tags)
+	}
+	return node.handle
+}
+
+// CounterVec creates counters which share a name and tag keys, e.g. for
+// counting requests by method and status:
+//
+//   requests := metrics.NewCounterVec(client, "requests.count", "method", "status")
+//
+//   // Later, on the hot path:
+//   requests.With("GET", "200").Inc()
+type CounterVec struct {
+	*vec
+}
+
+// NewCounterVec creates a new counter vector with tag keys in the order their
+// values are passed to `With`.
+func NewCounterVec(client Client, name string, keys ...string) *CounterVec {
+	return &CounterVec{newVec(client, name, keys, func(c Client, n string, t map[string]string) interface{} {
+		return NewCounter(c, n, t)
+	})}
+}
+
+// With returns the counter for the given tag values. It panics if the number
+// of values does not match the number of keys.
+func (v *CounterVec) With(values ...string) *Counter {
+	return v.get(values).(*Counter)
+}
+
+// GaugeVec creates gauges which share a name and tag keys.
+type GaugeVec struct {
+	*vec
+}
+
+// NewGaugeVec creates a new gauge vector with tag keys in the order their
+// values are passed to `With`.
+func NewGaugeVec(client Client, name string, keys ...string) *GaugeVec {
+	return &GaugeVec{newVec(client, name, keys, func(c Client, n string, t map[string]string) interface{} {
+		return NewGauge(c, n, t)
+	})}
+}
+
+// With returns the gauge for the given tag values. It panics if the number
+// of values does not match the number of keys.
+func (v *GaugeVec) With(values ...string) *Gauge {
+	return v.get(values).(*Gauge)
+}
+
+// TimerVec creates timers which share a name and tag keys.
+type TimerVec struct {
+	*vec
+}
+
+// NewTimerVec creates a new timer vector with tag keys in the order their
+// values are passed to `With`.
+func NewTimerVec(client Client, name string, keys ...string) *TimerVec {
+	return &TimerVec{newVec(client, name, keys, func(c Client, n string, t map[string]string) interface{} {
+		return NewTimer(c, n, t)
+	})}
+}
+
+// With returns the timer for the given tag values. It panics if the number
+// of values does not match the number of keys.
+func (v *TimerVec) With(values ...string) *Timer {
+	return v.get(values).(*Timer)
+}
+
+// HistogramVec creates histograms which share a name and tag keys.
+type HistogramVec struct {
+	*vec
+}
+
+// NewHistogramVec creates a new histogram vector with tag keys in the order
+// their values are passed to `With`.
+func NewHistogramVec(client Client, name string, keys ...string) *HistogramVec {
+	return &HistogramVec{newVec(client, name, keys, func(c Client, n string, t map[string]string) interface{} {
+		return NewHistogram(c, n, t)
+	})}
+}
+
+// With returns the histogram for the given tag values. It panics if the
+// number of values does not match the number of keys.
+func (v *HistogramVec) With(values ...string) *Histogram {
+	return v.get(values).(*Histogram)
+}
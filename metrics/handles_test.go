@@ -0,0 +1,170 @@
+package metrics_test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/istreamlabs/go-metrics/metrics"
+)
+
+func ExampleCounterVec() {
+	client := metrics.NewLoggerClient(nil)
+	requests := metrics.NewCounterVec(client, "requests.count", "method", "status")
+
+	requests.With("GET", "200").Inc()
+	// Output: Count requests.count:1 map[method:GET status:200]
+}
+
+func TestHandles(t *testing.T) {
+	recorder := metrics.NewRecorderClient().WithTest(t)
+	tags := map[string]string{"handler": "users"}
+
+	counter := metrics.NewCounter(recorder, "requests.count", tags)
+	counter.Add(5)
+	counter.Inc()
+	counter.Dec()
+
+	metrics.NewGauge(recorder, "queue.depth", nil).Set(3)
+
+	timer := metrics.NewTimer(recorder, "latency", tags)
+	timer.Record(time.Second)
+	timer.Since(time.Now())
+
+	metrics.NewHistogram(recorder, "size", tags).Observe(1.5)
+
+	// Changing the map afterward does not change bound tags.
+	tags["handler"] = "other"
+
+	recorder.Expect("requests.count").Value(5).Tag("handler", "users")
+	recorder.Expect("requests.count").Value(1).Tag("handler", "users")
+	recorder.Expect("requests.count").Value(-1).Tag("handler", "users")
+	recorder.Expect("queue.depth").Value(3).NoTagName("handler")
+	recorder.Expect("latency").Value(time.Second).Tag("handler", "users")
+	recorder.Expect("latency").MinTimes(2).Tag("handler", "users")
+	recorder.Expect("size").Value(1.5).Tag("handler", "users")
+}
+
+func TestHandleVecs(t *testing.T) {
+	recorder := metrics.NewRecorderClient().WithTest(t)
+
+	requests := metrics.NewCounterVec(recorder, "requests.count", "method", "status")
+	requests.With("GET", "200").Inc()
+	requests.With("GET", "200").Add(2)
+	requests.With("POST", "500").Inc()
+
+	if requests.With("GET", "200") != requests.With("GET", "200") {
+		t.Fatal("Expected cached counter")
+	}
+	if requests.With("GET", "200") == requests.With("GET", "500") {
+		t.Fatal("Expected different counters")
+	}
+
+	metrics.NewGaugeVec(recorder, "pool.size", "pool").With("db").Set(10)
+	metrics.NewTimerVec(recorder, "latency", "route").With("/users").Record(time.Millisecond)
+	metrics.NewHistogramVec(recorder, "size", "route").With("/users").Observe(100)
+
+	recorder.Expect("requests.count").Tags(map[string]string{"method": "GET", "status": "200"}).Value(1)
+	recorder.Expect("requests.count").Tags(map[string]string{"method": "GET", "status": "200"}).Value(2)
+	recorder.Expect("requests.count").Tags(map[string]string{"method": "POST", "status": "500"}).Value(1)
+	recorder.Expect("pool.size").Tag("pool", "db").Value(10)
+	recorder.Expect("latency").Tag("route", "/users").Value(time.Millisecond)
+	recorder.Expect("size").Tag("route", "/users").Value(100)
+}
+
+func TestHandleVecWrongValues(t *testing.T) {
+	requests := metrics.NewCounterVec(metrics.NewNullClient(), "requests.count", "method", "status")
+
+	defer func() {
+		r := recover()
+		if r == nil || !strings.Contains(r.(string), "expects 2 tag values [method status] but got 1") {
+			t.Fatalf("Expected panic but got %v", r)
+		}
+	}()
+	requests.With("GET")
+}
+
+func TestHandleVecConcurrent(t *testing.T) {
+	recorder := metrics.NewRecorderClient().WithTest(t)
+	requests := metrics.NewCounterVec(recorder, "requests.count", "method")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				requests.With([]string{"GET", "POST"}[j%2]).Inc()
+			}
+		}()
+	}
+	wg.Wait()
+
+	recorder.Expect("requests.count").MinTimes(500).Tag("method", "GET")
+	recorder.Expect("requests.count").MinTimes(500).Tag("method", "POST")
+}
+
+func TestHandlesAllClients(t *testing.T) {
+	for _, client := range []metrics.Client{
+		metrics.NewNullClient(),
+		metrics.NewLoggerClient(&LogRecorder{}),
+		metrics.NewRecorderClient(),
+		metrics.NewDataDogClient("127.0.0.1:8126", "testing", metrics.WithoutTelemetry()),
+	} {
+		requests := metrics.NewCounterVec(client, "requests.count", "method")
+		requests.With("GET").Inc()
+		metrics.NewGauge(client, "queue.depth", map[string]string{"queue": "jobs"}).Set(1)
+		metrics.NewTimer(client, "latency", nil).Record(time.Second)
+		metrics.NewHistogram(client, "size", nil).Observe(1)
+		client.Close()
+	}
+}
+
+func TestHandlesDoNotAllocate(t *testing.T) {
+	client := metrics.NewDataDogClient("127.0.0.1:8126", "testing", metrics.WithoutTelemetry())
+	defer client.Close()
+
+	// The statsd client may allocate internally, so handles are compared to
+	// calling a pre-tagged client directly.
+	bound := client.WithTags(map[string]string{"handler": "users"})
+	baseline := testing.AllocsPerRun(100, func() { bound.Incr("requests.count") })
+
+	counter := metrics.NewCounter(client, "requests.count", map[string]string{"handler": "users"})
+	requests := metrics.NewCounterVec(client, "requests.count", "method", "status")
+	requests.With("GET", "200").Inc()
+
+	if allocs := testing.AllocsPerRun(100, func() { counter.Inc() }); allocs > baseline {
+		t.Errorf("Expected at most %v allocations for Counter.Inc but got %v", baseline, allocs)
+	}
+	if allocs := testing.AllocsPerRun(100, func() { requests.With("GET", "200").Inc() }); allocs > baseline {
+		t.Errorf("Expected at most %v allocations for CounterVec.With.Inc but got %v", baseline, allocs)
+	}
+
+	null := metrics.NewCounterVec(metrics.NewNullClient(), "requests.count", "method", "status")
+	null.With("GET", "200").Inc()
+	if allocs := testing.AllocsPerRun(100, func() { null.With("GET", "200").Inc() }); allocs != 0 {
+		t.Errorf("Expected no allocations for CounterVec.With.Inc but got %v", allocs)
+	}
+}
+
+func BenchmarkCounterVec(b *testing.B) {
+	client := metrics.NewDataDogClient("127.0.0.1:8126", "testing", metrics.WithoutTelemetry())
+	defer client.Close()
+	requests := metrics.NewCounterVec(client, "requests.count", "method", "status")
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		requests.With("GET", "200").Inc()
+	}
+}
+
+func BenchmarkWithTags(b *testing.B) {
+	client := metrics.NewDataDogClient("127.0.0.1:8126", "testing", metrics.WithoutTelemetry())
+	defer client.Close()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		client.WithTags(map[string]string{"method": "GET", "status": "200"}).Incr("requests.count")
+	}
+}
@@ -0,0 +1,177 @@
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/DataDog/datadog-go/v5/statsd"
+)
+
+// RecorderOutputEnv is the environment variable which, when set to a file
+// path, causes `RecorderClient.Close` to write the recorded calls to that path
+// as JSON. This allows a test to run a subprocess which uses a recorder and
+// then load its metrics via `LoadRecorderClientFile`:
+//
+//   cmd := exec.Command("./my-service")
+//   cmd.Env = append(os.Environ(), metrics.RecorderOutputEnv+"="+path)
+//   cmd.Run()
+//
+//   recorder, err := metrics.LoadRecorderClientFile(path)
+//   recorder.WithTest(t).Expect("requests.count")
+const RecorderOutputEnv = "METRICS_RECORDER_OUTPUT"
+
+// jsonRecording is the serialized form of a recorder's calls.
+type jsonRecording struct {
+	Calls []jsonCall `json:"calls"`
+}
+
+// jsonCall is the serialized form of a metric or event call.
+type jsonCall struct {
+	Kind   string            `json:"kind"`
+	Type   string            `json:"type,omitempty"`
+	Name   string            `json:"name,omitempty"`
+	Value  float64           `json:"value,omitempty"`
+	Rate   float64           `json:"rate,omitempty"`
+	Event  *jsonEvent        `json:"event,omitempty"`
+	Tags   map[string]string `json:"tags"`
+	Time   time.Time         `json:"time"`
+	Caller string            `json:"caller,omitempty"`
+}
+
+// jsonEvent is the serialized form of a `statsd.Event`.
+type jsonEvent struct {
+	Title          string    `json:"title"`
+	Text           string    `json:"text"`
+	Timestamp      time.Time `json:"timestamp"`
+	Hostname       string    `json:"hostname,omitempty"`
+	AggregationKey string    `json:"aggregationKey,omitempty"`
+	Priority       string    `json:"priority,omitempty"`
+	SourceTypeName string    `json:"sourceTypeName,omitempty"`
+	AlertType      string    `json:"alertType,omitempty"`
+	Tags           []string  `json:"tags,omitempty"`
+}
+
+// WriteJSON writes all recorded calls to `w` as JSON.
+func (c *RecorderClient) WriteJSON(w io.Writer) error {
+	calls := c.CopyCalls()
+	recording := jsonRecording{Calls: make([]jsonCall, 0, len(calls))}
+	for _, call := range calls {
+		switch t := call.(type) {
+		case *MetricCall:
+			recording.Calls = append(recording.Calls, jsonCall{
+				Kind:   "metric",
+				Type:   t.Type,
+				Name:   t.Name,
+				Value:  t.Value,
+				Rate:   t.Rate,
+				Tags:   t.TagMap,
+				Time:   t.Time,
+				Caller: t.Caller,
+			})
+		case *EventCall:
+			recording.Calls = append(recording.Calls, jsonCall{
+				Kind: "event",
+				Event: &jsonEvent{
+					Title:          t.Event.Title,
+					Text:           t.Event.Text,
+					Timestamp:      t.Event.Timestamp,
+					Hostname:       t.Event.Hostname,
+					AggregationKey: t.Event.AggregationKey,
+					Priority:       string(t.Event.Priority),
+					SourceTypeName: t.Event.SourceTypeName,
+					AlertType:      string(t.Event.AlertType),
+					Tags:           t.Event.Tags,
+				},
+				Tags:   t.TagMap,
+				Time:   t.Time,
+				Caller: t.Caller,
+			})
+		}
+	}
+
+	return json.NewEncoder(w).Encode(recording)
+}
+
+// WriteJSONFile writes all recorded calls to a file as JSON.
+func (c *RecorderClient) WriteJSONFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := c.WriteJSON(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// LoadRecorderClient creates a new recording metrics client containing the
+// calls serialized via `WriteJSON`.
+func LoadRecorderClient(r io.Reader) (*RecorderClient, error) {
+	var recording jsonRecording
+	if err := json.NewDecoder(r).Decode(&recording); err != nil {
+		return nil, err
+	}
+
+	client := NewRecorderClient()
+	for i, call := range recording.Calls {
+		tags := call.Tags
+		if tags == nil {
+			tags = map[string]string{}
+		}
+
+		switch call.Kind {
+		case "metric":
+			client.callInfo.Calls = append(client.callInfo.Calls, &MetricCall{
+				Type:   call.Type,
+				Name:   call.Name,
+				Value:  call.Value,
+				Rate:   call.Rate,
+				TagMap: tags,
+				Time:   call.Time,
+				Caller: call.Caller,
+			})
+		case "event":
+			if call.Event == nil {
+				return nil, fmt.Errorf("call %d: missing event", i)
+			}
+			client.callInfo.Calls = append(client.callInfo.Calls, &EventCall{
+				Event: &statsd.Event{
+					Title:          call.Event.Title,
+					Text:           call.Event.Text,
+					Timestamp:      call.Event.Timestamp,
+					Hostname:       call.Event.Hostname,
+					AggregationKey: call.Event.AggregationKey,
+					Priority:       statsd.EventPriority(call.Event.Priority),
+					SourceTypeName: call.Event.SourceTypeName,
+					AlertType:      statsd.EventAlertType(call.Event.AlertType),
+					Tags:           call.Event.Tags,
+				},
+				TagMap: tags,
+				Time:   call.Time,
+				Caller: call.Caller,
+			})
+		default:
+			return nil, fmt.Errorf("call %d: unknown kind '%s'", i, call.Kind)
+		}
+	}
+
+	return client, nil
+}
+
+// LoadRecorderClientFile creates a new recording metrics client containing
+// the calls from a file written via `WriteJSONFile` or by `Close` when the
+// `METRICS_RECORDER_OUTPUT` environment variable is set.
+func LoadRecorderClientFile(path string) (*RecorderClient, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return LoadRecorderClient(f)
+}
@@ -0,0 +1,92 @@
+package metrics_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-go/v5/statsd"
+	"github.com/istreamlabs/go-metrics/metrics"
+)
+
+func TestRecorderJSON(t *testing.T) {
+	recorder := metrics.NewRecorderClient()
+	recorder.WithTags(map[string]string{"env": "prod"}).WithRate(0.5).Timing("latency", time.Second)
+	recorder.Event(&statsd.Event{
+		Title:     "deploy",
+		Text:      "v1",
+		AlertType: statsd.Error,
+		Tags:      []string{"team:video"},
+	})
+
+	var buf bytes.Buffer
+	if err := recorder.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := metrics.LoadRecorderClient(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded = loaded.WithTest(t)
+
+	loaded.Expect("latency").Value(time.Second).Rate(0.5).Tags(map[string]string{"env": "prod"})
+	loaded.Expect("deploy").Text("v1").AlertType(statsd.Error)
+	ExpectEqual(t, recorder.GetCalls()[0].String(), loaded.GetCalls()[0].String())
+	ExpectEqual(t, "Timing", loaded.GetCalls()[0].(*metrics.MetricCall).Type)
+	ExpectEqual(t, []string{"team:video"}, loaded.GetCalls()[1].(*metrics.EventCall).Event.Tags)
+
+	if _, err := metrics.LoadRecorderClient(bytes.NewBufferString(`{"calls": [{"kind": "bad"}]}`)); err == nil {
+		t.Fatal("Expected an error loading an unknown call kind")
+	}
+}
+
+func TestRecorderOutputEnv(t *testing.T) {
+	// When run as a subprocess, emit metrics and exit.
+	if os.Getenv(metrics.RecorderOutputEnv) != "" {
+		recorder := metrics.NewRecorderClient()
+		clone := recorder.WithTags(map[string]string{"process": "child"})
+		scope := recorder.Scope()
+		clone.Incr("child.requests")
+		scope.Incr("child.scoped")
+
+		// Only the first close of the root recorder or a clone writes the
+		// calls, and scopes never overwrite them with their partial view.
+		if err := clone.Close(); err != nil {
+			t.Fatal(err)
+		}
+		recorder.Incr("child.late")
+		for _, c := range []metrics.Client{scope, recorder} {
+			if err := c.Close(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return
+	}
+
+	dir, err := ioutil.TempDir("", "recorder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "metrics.json")
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestRecorderOutputEnv$")
+	cmd.Env = append(os.Environ(), metrics.RecorderOutputEnv+"="+path)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("Subprocess failed: %v\n%s", err, out)
+	}
+
+	recorder, err := metrics.LoadRecorderClientFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder = recorder.WithTest(t)
+	recorder.Expect("child.requests").Tag("process", "child")
+	recorder.Expect("child.scoped")
+	recorder.If("child.late").Reject()
+}
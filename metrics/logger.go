@@ -100,6 +100,15 @@ func (c *LoggerClient) WithRate(rate float64) Client {
 
 // print out the metric call, taking into account sample rate.
 func (c *LoggerClient) print(t string, name string, value interface{}, sampled interface{}) {
+	if c.rate != 1.0 && rand.Float64() >= c.rate {
+		return
+	}
+
+	c.write(t, name, value, sampled)
+}
+
+// write out the metric call without sampling.
+func (c *LoggerClient) write(t string, name string, value interface{}, sampled interface{}) {
 	r := fmt.Sprintf("%v", c.rate)
 	v := value
 	s := sampled
@@ -116,12 +125,10 @@ func (c *LoggerClient) print(t string, name string, value interface{}, sampled i
 		return
 	}
 
-	if rand.Float64() < c.rate {
-		if value == sampled {
-			c.logger.Printf("%s %s:%v (%v) %v", t, name, v, r, c.getTags())
-		} else {
-			c.logger.Printf("%s %s:%v (%v * %v) %v", t, name, s, v, r, c.getTags())
-		}
+	if value == sampled {
+		c.logger.Printf("%s %s:%v (%v) %v", t, name, v, r, c.getTags())
+	} else {
+		c.logger.Printf("%s %s:%v (%v * %v) %v", t, name, s, v, r, c.getTags())
 	}
 }
 
@@ -148,6 +155,46 @@ func (c *LoggerClient) getTags() string {
 	return "map[" + tags + "]"
 }
 
+// Datagram logs a parsed DogStatsD datagram, e.g. one received from another
+// process. Since the sender has already applied its sample rate, the
+// datagram is always logged.
+func (c *LoggerClient) Datagram(d Datagram) {
+	rate := d.Rate
+	if rate == 0 {
+		// Events and service checks are not sampled.
+		rate = 1.0
+	}
+	client := &LoggerClient{
+		logger: c.logger,
+		rate:   rate,
+		colors: c.colors,
+		tagMap: combine(c.tagMap, tagsToMap(d.Tags)),
+	}
+
+	switch d.Kind {
+	case DatagramEvent:
+		client.Event(d.Event)
+	case DatagramServiceCheck:
+		client.write("ServiceCheck", d.Name, int(d.ServiceCheck.Status), int(d.ServiceCheck.Status))
+	default:
+		if d.Type == "Set" {
+			client.write(d.Type, d.Name, d.Member, d.Member)
+			return
+		}
+		for _, v := range d.Values {
+			switch d.Type {
+			case "Count":
+				client.write(d.Type, d.Name, int64(v), v*d.Rate)
+			case "Timing":
+				duration := time.Duration(v * float64(time.Millisecond))
+				client.write(d.Type, d.Name, duration, duration)
+			default:
+				client.write(d.Type, d.Name, v, v)
+			}
+		}
+	}
+}
+
 // Close on LoggerClient is a no-op
 func (c *LoggerClient) Close() error {
 	return nil
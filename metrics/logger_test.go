@@ -80,3 +80,28 @@ func TestLoggerClient(t *testing.T) {
 
 	ExpectEqual(t, expected, recorder.messages[len(recorder.messages)-1])
 }
+
+func TestLoggerClientDatagram(t *testing.T) {
+	recorder := &LogRecorder{}
+	client := metrics.NewLoggerClient(recorder)
+
+	datagrams, err := metrics.ParseDogStatsD([]byte("requests:1|c|#env:prod\n" +
+		"sampled:2|g|@0.1\n" +
+		"latency:1500|ms\n" +
+		"_e{6,2}:deploy|v1|#team:video\n" +
+		"_sc|db.health|2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range datagrams {
+		client.Datagram(d)
+	}
+
+	ExpectEqual(t, []string{
+		"Count requests:1 map[env:prod]",
+		"Gauge sampled:2 (0.1) map[]",
+		"Timing latency:1.5s map[]",
+		"Event deploy\nv1 map[team:video]",
+		"ServiceCheck db.health:2 map[]",
+	}, recorder.messages)
+}
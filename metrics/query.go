@@ -2,8 +2,15 @@ package metrics
 
 import (
 	"fmt"
+	"math"
 	"reflect"
+	"regexp"
+	"sort"
 	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-go/v5/statsd"
+	"github.com/istreamlabs/go-metrics/internal/levenshtein"
 )
 
 // Query provides a mechanism to filter and test metrics for given chainable
@@ -66,14 +73,87 @@ type Query interface {
 	// tag with name `name`. The value does not matter.
 	TagName(name string) Query
 
+	// TagMatch filters out any metric or event that does not contain a tag
+	// with name `name` whose value matches the regular expression `pattern`.
+	// Panics if the pattern cannot be compiled.
+	TagMatch(name, pattern string) Query
+
+	// Tags filters out any metric or event whose tag set is not exactly
+	// equal to `tags`. Extra or missing tags cause the call to be removed.
+	Tags(tags map[string]string) Query
+
+	// OnlyTagNames filters out any metric or event that has a tag whose name
+	// is not one of `names`. Not every name needs to be present.
+	OnlyTagNames(names ...string) Query
+
+	// NoTagName filters out any metric or event that contains a tag with
+	// name `name`.
+	NoTagName(name string) Query
+
 	// Rate filters out any metric that does not have the given sample rate.
 	Rate(rate float64) Query
+
+	// After filters out any metric or event emitted before `t`.
+	After(t time.Time) Query
+
+	// Before filters out any metric or event emitted after `t`.
+	Before(t time.Time) Query
+
+	// Not filters out any metric or event that matches the sub-query, which
+	// must be created via `metrics.Match()`.
+	Not(sub Query) Query
+
+	// Or filters out any metric or event that does not match at least one of
+	// the sub-queries, which must be created via `metrics.Match()`.
+	Or(subs ...Query) Query
+
+	// Where filters out any metric or event for which `pred` returns false.
+	// The `description` is used in failure messages.
+	Where(description string, pred func(Call) bool) Query
+
+	// Priority filters out any event that does not have the given priority.
+	// Events without a priority are treated as `statsd.Normal`, like
+	// DogStatsD does. All metrics are filtered out.
+	Priority(priority statsd.EventPriority) Query
+
+	// AlertType filters out any event that does not have the given alert
+	// type. Events without an alert type are treated as `statsd.Info`, like
+	// DogStatsD does. All metrics are filtered out.
+	AlertType(alertType statsd.EventAlertType) Query
+
+	// AggregationKey filters out any event that does not have the given
+	// aggregation key. All metrics are filtered out.
+	AggregationKey(key string) Query
+
+	// SourceTypeName filters out any event that does not have the given
+	// source type name. All metrics are filtered out.
+	SourceTypeName(name string) Query
+
+	// Hostname filters out any event that does not have the given hostname.
+	// All metrics are filtered out.
+	Hostname(hostname string) Query
+
+	// Timestamp filters out any event whose timestamp is not within `from`
+	// and `to`, inclusive. All metrics are filtered out.
+	Timestamp(from, to time.Time) Query
 }
 
 // query is an implementation of the `Query` interface.
 type query struct {
-	calls []Call
-	test  TestFailer
+	calls  []Call
+	test   TestFailer
+	helper helper
+
+	// failed is set once a failure has been reported, so that a non-fatal
+	// test does not get a cascade of failures from a single query.
+	failed bool
+
+	// all stores every call the query started with, used to find the nearest
+	// calls when a query fails.
+	all []Call
+
+	// checks stores every filter that has been applied, in order.
+	checks []check
 
 	// The minimum number of calls that should exist after filter operations.
 	minCalls int
@@ -85,8 +165,23 @@ type query struct {
 	history string
 }
 
+// check is a single query filter operation.
+type check struct {
+	// match describes a call which passes the check, e.g. `name matched`.
+	match string
+
+	// mismatch returns an empty string if the call passes the check, otherwise
+	// a description of why it does not, e.g. `value 2 not 1`.
+	mismatch func(Call) string
+
+	// distance optionally returns how far a call is from passing the check,
+	// which is used to rank calls that fail the same number of checks.
+	distance func(Call) float64
+}
+
 // Reject fails the test if at least the minimum number of items are left.
 func (q *query) Reject() {
+	q.helper.Helper()
 	if len(q.calls) >= q.minCalls {
 		stack := make([]string, 0, len(q.calls))
 		for _, call := range q.calls {
@@ -98,9 +193,10 @@ func (q *query) Reject() {
 
 // Accept fails the test if fewer than the minimum number of items are left.
 func (q *query) Accept() {
+	q.helper.Helper()
 	if len(q.calls) < q.minCalls {
 		if len(q.calls) == 0 {
-			q.test.Fatalf("Expected at least %d calls but have none", q.minCalls)
+			q.fatalf("Expected at least %d calls but have none", q.minCalls)
 		} else {
 			stack := make([]string, 0, len(q.calls))
 			for _, call := range q.calls {
@@ -113,6 +209,7 @@ func (q *query) Accept() {
 
 // MinTimes sets the minimum required number of calls.
 func (q *query) MinTimes(num int) Query {
+	q.helper.Helper()
 	q.history = fmt.Sprintf("%s minTimes(%d)", q.history, num)
 	q.minCalls = num
 
@@ -129,9 +226,77 @@ func (q *query) GetCalls() []Call {
 }
 
 // fatalf passes along a failure message to the test failer with additional
-// information about the state of the metrics query.
+// information about the state of the metrics query. When too few calls
+// match, the recorded calls nearest to the query are described as well.
 func (q *query) fatalf(format string, args ...interface{}) {
-	q.test.Fatalf(format+". Query was '%s'.", append(args, strings.Trim(q.history, " "))...)
+	q.helper.Helper()
+	if q.failed {
+		return
+	}
+	q.failed = true
+
+	format += ". Query was '%s'."
+	args = append(args, strings.Trim(q.history, " "))
+
+	if len(q.calls) < q.minCalls {
+		if nearest := q.nearest(); nearest != "" {
+			format += " Nearest calls:%s"
+			args = append(args, nearest)
+		}
+	}
+
+	q.test.Fatalf(format, args...)
+}
+
+// maxNearest is the maximum number of calls described by `nearest`.
+const maxNearest = 3
+
+// nearest returns a description of the calls which failed the fewest query
+// checks, along with how each one differs from the query. For example:
+//
+//   'my.metric:2[env:prod]': name matched, tag env was 'prod' not 'staging', value 2 not 1
+func (q *query) nearest() string {
+	type candidate struct {
+		call     Call
+		failed   int
+		distance float64
+		diffs    []string
+	}
+
+	candidates := make([]candidate, 0, len(q.all))
+	for _, call := range q.all {
+		c := candidate{call: call}
+		for _, chk := range q.checks {
+			if mismatch := chk.mismatch(call); mismatch != "" {
+				c.failed++
+				c.diffs = append(c.diffs, mismatch)
+				if chk.distance != nil {
+					c.distance += chk.distance(call)
+				}
+			} else {
+				c.diffs = append(c.diffs, chk.match)
+			}
+		}
+		if c.failed > 0 {
+			candidates = append(candidates, c)
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].failed != candidates[j].failed {
+			return candidates[i].failed < candidates[j].failed
+		}
+		return candidates[i].distance < candidates[j].distance
+	})
+	if len(candidates) > maxNearest {
+		candidates = candidates[:maxNearest]
+	}
+
+	buf := ""
+	for _, c := range candidates {
+		buf += fmt.Sprintf("\n\t'%s': %s", describeCall(c.call), strings.Join(c.diffs, ", "))
+	}
+	return buf
 }
 
 // filter will remove calls from the call list given an expected value,
@@ -149,16 +314,31 @@ func (q *query) filter(pred func(Call) bool) {
 	q.calls = filtered
 }
 
+// apply records a check and removes any calls which do not pass it.
+func (q *query) apply(c check) {
+	q.checks = append(q.checks, c)
+	q.filter(func(call Call) bool {
+		return c.mismatch(call) == ""
+	})
+}
+
 // Contains checks whether the serialized metric contains the given
 // string value. See `Call.String()` for the serialization format.
 func (q *query) Contains(component string) Query {
+	q.helper.Helper()
 	q.history = fmt.Sprintf("%s contains(%s)", q.history, component)
-	q.filter(func(call Call) bool {
-		return strings.Contains(call.String(), component)
+	q.apply(check{
+		match: fmt.Sprintf("contains '%s'", component),
+		mismatch: func(call Call) string {
+			if !strings.Contains(call.String(), component) {
+				return fmt.Sprintf("does not contain '%s'", component)
+			}
+			return ""
+		},
 	})
 
 	if q.checkMin && len(q.calls) < q.minCalls {
-		q.test.Fatalf("Expected metric or event to contain '%s'", component)
+		q.fatalf("Expected metric or event to contain '%s'", component)
 	}
 
 	return q
@@ -166,19 +346,32 @@ func (q *query) Contains(component string) Query {
 
 // ID expects a metric name or event title.
 func (q *query) ID(id string) Query {
+	q.helper.Helper()
 	q.history = fmt.Sprintf("%s id(%s)", q.history, id)
-	q.filter(func(call Call) bool {
-		switch t := call.(type) {
-		case *MetricCall:
-			if t.Name == id {
-				return true
+	q.apply(check{
+		match: "name matched",
+		mismatch: func(call Call) string {
+			switch t := call.(type) {
+			case *MetricCall:
+				if t.Name != id {
+					return fmt.Sprintf("name was '%s' not '%s'", t.Name, id)
+				}
+			case *EventCall:
+				if t.Event.Title != id {
+					return fmt.Sprintf("title was '%s' not '%s'", t.Event.Title, id)
+				}
 			}
-		case *EventCall:
-			if t.Event.Title == id {
-				return true
+			return ""
+		},
+		distance: func(call Call) float64 {
+			switch t := call.(type) {
+			case *MetricCall:
+				return float64(levenshtein.Distance(t.Name, id))
+			case *EventCall:
+				return float64(levenshtein.Distance(t.Event.Title, id))
 			}
-		}
-		return false
+			return 0
+		},
 	})
 
 	if q.checkMin && len(q.calls) < q.minCalls {
@@ -190,12 +383,27 @@ func (q *query) ID(id string) Query {
 
 // Value expects a metric value.
 func (q *query) Value(value interface{}) Query {
+	q.helper.Helper()
+	expected := toFloat64(value)
 	q.history = fmt.Sprintf("%s value(%v)", q.history, value)
-	q.filter(func(call Call) bool {
-		if m, ok := call.(*MetricCall); ok {
-			return reflect.DeepEqual(m.Value, toFloat64(value))
-		}
-		return false
+	q.apply(check{
+		match: "value matched",
+		mismatch: func(call Call) string {
+			m, ok := call.(*MetricCall)
+			if !ok {
+				return "is an event"
+			}
+			if !reflect.DeepEqual(m.Value, expected) {
+				return fmt.Sprintf("value %v not %v", m.Value, value)
+			}
+			return ""
+		},
+		distance: func(call Call) float64 {
+			if m, ok := call.(*MetricCall); ok {
+				return math.Abs(m.Value - expected)
+			}
+			return 0
+		},
 	})
 
 	if q.checkMin && len(q.calls) < q.minCalls {
@@ -207,12 +415,20 @@ func (q *query) Value(value interface{}) Query {
 
 // Text expects an event with the given text content value.
 func (q *query) Text(text string) Query {
+	q.helper.Helper()
 	q.history = fmt.Sprintf("%s text(%10s)", q.history, text)
-	q.filter(func(call Call) bool {
-		if e, ok := call.(*EventCall); ok {
-			return e.Event.Text == text
-		}
-		return false
+	q.apply(check{
+		match: "text matched",
+		mismatch: func(call Call) string {
+			e, ok := call.(*EventCall)
+			if !ok {
+				return "is a metric"
+			}
+			if e.Event.Text != text {
+				return fmt.Sprintf("text was '%s' not '%s'", e.Event.Text, text)
+			}
+			return ""
+		},
 	})
 
 	if q.checkMin && len(q.calls) < q.minCalls {
@@ -224,19 +440,20 @@ func (q *query) Text(text string) Query {
 
 // Tag expects a tag name and value to be set with the emitted metric.
 func (q *query) Tag(name, value string) Query {
+	q.helper.Helper()
 	q.history = fmt.Sprintf("%s tag(%s, %s)", q.history, name, value)
-	q.filter(func(call Call) bool {
-		switch t := call.(type) {
-		case *MetricCall:
-			if v, ok := t.TagMap[name]; ok {
-				return v == value
+	q.apply(check{
+		match: fmt.Sprintf("tag %s matched", name),
+		mismatch: func(call Call) string {
+			v, ok := tagMapOf(call)[name]
+			if !ok {
+				return fmt.Sprintf("missing tag %s", name)
 			}
-		case *EventCall:
-			if v, ok := t.TagMap[name]; ok {
-				return v == value
+			if v != value {
+				return fmt.Sprintf("tag %s was '%s' not '%s'", name, v, value)
 			}
-		}
-		return false
+			return ""
+		},
 	})
 
 	if q.checkMin && len(q.calls) < q.minCalls {
@@ -248,19 +465,16 @@ func (q *query) Tag(name, value string) Query {
 
 // TagName expects a tag name to exist.
 func (q *query) TagName(name string) Query {
+	q.helper.Helper()
 	q.history = fmt.Sprintf("%s tag(%s)", q.history, name)
-	q.filter(func(call Call) bool {
-		switch t := call.(type) {
-		case *MetricCall:
-			if _, ok := t.TagMap[name]; ok {
-				return true
-			}
-		case *EventCall:
-			if _, ok := t.TagMap[name]; ok {
-				return true
+	q.apply(check{
+		match: fmt.Sprintf("tag %s present", name),
+		mismatch: func(call Call) string {
+			if _, ok := tagMapOf(call)[name]; !ok {
+				return fmt.Sprintf("missing tag %s", name)
 			}
-		}
-		return false
+			return ""
+		},
 	})
 
 	if q.checkMin && len(q.calls) < q.minCalls {
@@ -270,17 +484,134 @@ func (q *query) TagName(name string) Query {
 	return q
 }
 
+// TagMatch expects a tag name to exist with a value matching a regular
+// expression.
+func (q *query) TagMatch(name, pattern string) Query {
+	q.helper.Helper()
+	re := regexp.MustCompile(pattern)
+	q.history = fmt.Sprintf("%s tagMatch(%s, %s)", q.history, name, pattern)
+	q.apply(check{
+		match: fmt.Sprintf("tag %s matched", name),
+		mismatch: func(call Call) string {
+			v, ok := tagMapOf(call)[name]
+			if !ok {
+				return fmt.Sprintf("missing tag %s", name)
+			}
+			if !re.MatchString(v) {
+				return fmt.Sprintf("tag %s was '%s' not matching '%s'", name, v, pattern)
+			}
+			return ""
+		},
+	})
+
+	if q.checkMin && len(q.calls) < q.minCalls {
+		q.fatalf("Expected tag '%s' with value matching '%s'", name, pattern)
+	}
+
+	return q
+}
+
+// Tags expects the tag set to be exactly equal to the given map.
+func (q *query) Tags(tags map[string]string) Query {
+	q.helper.Helper()
+	q.history = fmt.Sprintf("%s tags(%s)", q.history, formatTags(tags))
+	q.apply(check{
+		match: "tags matched",
+		mismatch: func(call Call) string {
+			actual := tagMapOf(call)
+			if diffs := tagDiff(tags, actual); len(diffs) > 0 {
+				return fmt.Sprintf("tags expected %s, actual %s (%s)", formatTags(tags), formatTags(actual), strings.Join(diffs, ", "))
+			}
+			return ""
+		},
+		distance: func(call Call) float64 {
+			return float64(len(tagDiff(tags, tagMapOf(call))))
+		},
+	})
+
+	if q.checkMin && len(q.calls) < q.minCalls {
+		q.fatalf("Expected tags to be exactly '%s'", formatTags(tags))
+	}
+
+	return q
+}
+
+// OnlyTagNames expects every tag name to be one of the allowed names.
+func (q *query) OnlyTagNames(names ...string) Query {
+	q.helper.Helper()
+	allowed := make(map[string]bool, len(names))
+	for _, name := range names {
+		allowed[name] = true
+	}
+
+	q.history = fmt.Sprintf("%s onlyTagNames(%s)", q.history, strings.Join(names, ", "))
+	q.apply(check{
+		match: "tag names allowed",
+		mismatch: func(call Call) string {
+			var unexpected []string
+			for k := range tagMapOf(call) {
+				if !allowed[k] {
+					unexpected = append(unexpected, k)
+				}
+			}
+			if len(unexpected) > 0 {
+				sort.Strings(unexpected)
+				return fmt.Sprintf("unexpected tag %s", strings.Join(unexpected, ", "))
+			}
+			return ""
+		},
+	})
+
+	if q.checkMin && len(q.calls) < q.minCalls {
+		q.fatalf("Expected only tag names '%s'", strings.Join(names, ", "))
+	}
+
+	return q
+}
+
+// NoTagName expects a tag name to not exist.
+func (q *query) NoTagName(name string) Query {
+	q.helper.Helper()
+	q.history = fmt.Sprintf("%s noTag(%s)", q.history, name)
+	q.apply(check{
+		match: fmt.Sprintf("tag %s absent", name),
+		mismatch: func(call Call) string {
+			if _, ok := tagMapOf(call)[name]; ok {
+				return fmt.Sprintf("unexpected tag %s", name)
+			}
+			return ""
+		},
+	})
+
+	if q.checkMin && len(q.calls) < q.minCalls {
+		q.fatalf("Expected no tag '%s'", name)
+	}
+
+	return q
+}
+
 // Rate expects a value with the given rate to exist.
 func (q *query) Rate(rate float64) Query {
+	q.helper.Helper()
 	q.history = fmt.Sprintf("%s rate(%f)", q.history, rate)
-	q.filter(func(call Call) bool {
-		switch t := call.(type) {
-		case *MetricCall:
-			return t.Rate == rate
-		case *EventCall:
-			return false
-		}
-		return false
+	q.apply(check{
+		match: "rate matched",
+		mismatch: func(call Call) string {
+			m, ok := call.(*MetricCall)
+			if !ok {
+				return "is an event"
+			}
+			if m.Rate != rate {
+				return fmt.Sprintf("rate %v not %v", m.Rate, rate)
+			}
+			return ""
+		},
+		distance: func(call Call) float64 {
+			if m, ok := call.(*MetricCall); ok {
+				return math.Abs(m.Rate - rate)
+			}
+			return 0
+		},
 	})
 
 	if q.checkMin && len(q.calls) < q.minCalls {
@@ -289,3 +620,277 @@ func (q *query) Rate(rate float64) Query {
 
 	return q
 }
+
+// After expects a metric or event emitted after the given time.
+func (q *query) After(t time.Time) Query {
+	q.helper.Helper()
+	q.history = fmt.Sprintf("%s after(%s)", q.history, t.Format(time.RFC3339Nano))
+	q.apply(check{
+		match: "time matched",
+		mismatch: func(call Call) string {
+			if at := timeOf(call); !at.After(t) {
+				return fmt.Sprintf("emitted %s before", t.Sub(at))
+			}
+			return ""
+		},
+	})
+
+	if q.checkMin && len(q.calls) < q.minCalls {
+		q.fatalf("Expected metric or event emitted after '%s'", t.Format(time.RFC3339Nano))
+	}
+
+	return q
+}
+
+// Before expects a metric or event emitted before the given time.
+func (q *query) Before(t time.Time) Query {
+	q.helper.Helper()
+	q.history = fmt.Sprintf("%s before(%s)", q.history, t.Format(time.RFC3339Nano))
+	q.apply(check{
+		match: "time matched",
+		mismatch: func(call Call) string {
+			if at := timeOf(call); !at.Before(t) {
+				return fmt.Sprintf("emitted %s after", at.Sub(t))
+			}
+			return ""
+		},
+	})
+
+	if q.checkMin && len(q.calls) < q.minCalls {
+		q.fatalf("Expected metric or event emitted before '%s'", t.Format(time.RFC3339Nano))
+	}
+
+	return q
+}
+
+// Not expects calls which do not match a sub-query.
+func (q *query) Not(sub Query) Query {
+	q.helper.Helper()
+	e := toExpectation(sub)
+	desc := fmt.Sprintf("not(%s)", e.describe())
+	q.history = fmt.Sprintf("%s %s", q.history, desc)
+	q.apply(check{
+		match: fmt.Sprintf("%s matched", desc),
+		mismatch: func(call Call) string {
+			if e.matches(call) {
+				return fmt.Sprintf("%s failed", desc)
+			}
+			return ""
+		},
+	})
+
+	if q.checkMin && len(q.calls) < q.minCalls {
+		q.fatalf("Expected metric or event not matching '%s'", e.describe())
+	}
+
+	return q
+}
+
+// Or expects calls which match at least one of the sub-queries.
+func (q *query) Or(subs ...Query) Query {
+	q.helper.Helper()
+	expectations := make([]*expectation, 0, len(subs))
+	descriptions := make([]string, 0, len(subs))
+	for _, sub := range subs {
+		e := toExpectation(sub)
+		expectations = append(expectations, e)
+		descriptions = append(descriptions, e.describe())
+	}
+	desc := fmt.Sprintf("or(%s)", strings.Join(descriptions, " | "))
+	q.history = fmt.Sprintf("%s %s", q.history, desc)
+	q.apply(check{
+		match: fmt.Sprintf("%s matched", desc),
+		mismatch: func(call Call) string {
+			for _, e := range expectations {
+				if e.matches(call) {
+					return ""
+				}
+			}
+			return fmt.Sprintf("%s failed", desc)
+		},
+	})
+
+	if q.checkMin && len(q.calls) < q.minCalls {
+		q.fatalf("Expected metric or event matching one of '%s'", strings.Join(descriptions, "', '"))
+	}
+
+	return q
+}
+
+// Where expects calls for which a predicate returns true.
+func (q *query) Where(description string, pred func(Call) bool) Query {
+	q.helper.Helper()
+	q.history = fmt.Sprintf("%s where(%s)", q.history, description)
+	q.apply(check{
+		match: fmt.Sprintf("where(%s) matched", description),
+		mismatch: func(call Call) string {
+			if !pred(call) {
+				return fmt.Sprintf("where(%s) failed", description)
+			}
+			return ""
+		},
+	})
+
+	if q.checkMin && len(q.calls) < q.minCalls {
+		q.fatalf("Expected metric or event where '%s'", description)
+	}
+
+	return q
+}
+
+// eventField expects an event with a string field equal to `expected`.
+func (q *query) eventField(field, expected string, get func(*statsd.Event) string) Query {
+	q.helper.Helper()
+	q.history = fmt.Sprintf("%s %s(%s)", q.history, field, expected)
+	q.apply(check{
+		match: fmt.Sprintf("%s matched", field),
+		mismatch: func(call Call) string {
+			e, ok := call.(*EventCall)
+			if !ok {
+				return "is a metric"
+			}
+			if actual := get(e.Event); actual != expected {
+				return fmt.Sprintf("%s was '%s' not '%s'", field, actual, expected)
+			}
+			return ""
+		},
+	})
+
+	if q.checkMin && len(q.calls) < q.minCalls {
+		q.fatalf("Expected event %s '%s'", field, expected)
+	}
+
+	return q
+}
+
+// Priority expects an event with the given priority.
+func (q *query) Priority(priority statsd.EventPriority) Query {
+	q.helper.Helper()
+	return q.eventField("priority", string(eventPriority(priority)), func(e *statsd.Event) string {
+		return string(eventPriority(e.Priority))
+	})
+}
+
+// AlertType expects an event with the given alert type.
+func (q *query) AlertType(alertType statsd.EventAlertType) Query {
+	q.helper.Helper()
+	return q.eventField("alertType", string(eventAlertType(alertType)), func(e *statsd.Event) string {
+		return string(eventAlertType(e.AlertType))
+	})
+}
+
+// eventPriority returns the priority DogStatsD uses for an event, which is
+// `normal` when none is set.
+func eventPriority(priority statsd.EventPriority) statsd.EventPriority {
+	if priority == "" {
+		return statsd.Normal
+	}
+	return priority
+}
+
+// eventAlertType returns the alert type DogStatsD uses for an event, which is
+// `info` when none is set.
+func eventAlertType(alertType statsd.EventAlertType) statsd.EventAlertType {
+	if alertType == "" {
+		return statsd.Info
+	}
+	return alertType
+}
+
+// AggregationKey expects an event with the given aggregation key.
+func (q *query) AggregationKey(key string) Query {
+	q.helper.Helper()
+	return q.eventField("aggregationKey", key, func(e *statsd.Event) string {
+		return e.AggregationKey
+	})
+}
+
+// SourceTypeName expects an event with the given source type name.
+func (q *query) SourceTypeName(name string) Query {
+	q.helper.Helper()
+	return q.eventField("sourceTypeName", name, func(e *statsd.Event) string {
+		return e.SourceTypeName
+	})
+}
+
+// Hostname expects an event with the given hostname.
+func (q *query) Hostname(hostname string) Query {
+	q.helper.Helper()
+	return q.eventField("hostname", hostname, func(e *statsd.Event) string {
+		return e.Hostname
+	})
+}
+
+// Timestamp expects an event with a timestamp within the given range.
+func (q *query) Timestamp(from, to time.Time) Query {
+	q.helper.Helper()
+	q.history = fmt.Sprintf("%s timestamp(%s, %s)", q.history, from.Format(time.RFC3339), to.Format(time.RFC3339))
+	q.apply(check{
+		match: "timestamp matched",
+		mismatch: func(call Call) string {
+			e, ok := call.(*EventCall)
+			if !ok {
+				return "is a metric"
+			}
+			if ts := e.Event.Timestamp; ts.Before(from) || ts.After(to) {
+				return fmt.Sprintf("timestamp was '%s'", ts.Format(time.RFC3339))
+			}
+			return ""
+		},
+	})
+
+	if q.checkMin && len(q.calls) < q.minCalls {
+		q.fatalf("Expected event timestamp between '%s' and '%s'", from.Format(time.RFC3339), to.Format(time.RFC3339))
+	}
+
+	return q
+}
+
+// timeOf returns the time a metric or event call was emitted.
+func timeOf(call Call) time.Time {
+	switch t := call.(type) {
+	case *MetricCall:
+		return t.Time
+	case *EventCall:
+		return t.Time
+	}
+	return time.Time{}
+}
+
+// tagMapOf returns the tag map of a metric or event call.
+func tagMapOf(call Call) map[string]string {
+	switch t := call.(type) {
+	case *MetricCall:
+		return t.TagMap
+	case *EventCall:
+		return t.TagMap
+	}
+	return nil
+}
+
+// formatTags returns a sorted, serialized representation of a tag map.
+func formatTags(tags map[string]string) string {
+	serialized := mapToStrings(tags)
+	sort.Strings(serialized)
+	return fmt.Sprintf("%v", serialized)
+}
+
+// tagDiff returns a list of human-readable differences between an expected
+// and actual tag set, e.g. `missing env:prod` or `env was 'dev' not 'prod'`.
+func tagDiff(expected, actual map[string]string) []string {
+	var diffs []string
+	for k, v := range expected {
+		if av, ok := actual[k]; !ok {
+			diffs = append(diffs, fmt.Sprintf("missing %s", buildTag(k, v)))
+		} else if av != v {
+			diffs = append(diffs, fmt.Sprintf("%s was '%s' not '%s'", k, av, v))
+		}
+	}
+	for k, v := range actual {
+		if _, ok := expected[k]; !ok {
+			diffs = append(diffs, fmt.Sprintf("unexpected %s", buildTag(k, v)))
+		}
+	}
+	sort.Strings(diffs)
+	return diffs
+}
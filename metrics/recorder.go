@@ -2,6 +2,7 @@ package metrics
 
 import (
 	"fmt"
+	"os"
 	"path"
 	"runtime"
 	"sort"
@@ -36,13 +37,49 @@ type TestFailer interface {
 	Fatalf(format string, args ...interface{})
 }
 
+// TestErrorer provides a method to report a test failure without stopping
+// the test, allowing multiple failed expectations to be collected in a
+// single run. The built-in `testing.T` and `testing.B` structs implement it.
+type TestErrorer interface {
+	Errorf(format string, args ...interface{})
+}
+
+// helper is implemented by test instances which can mark functions as test
+// helpers, e.g. `testing.T`, so that failures are reported at the line in
+// the test rather than within this package.
+type helper interface {
+	Helper()
+}
+
+// noHelper is used when the attached test does not support helpers.
+type noHelper struct{}
+
+func (noHelper) Helper() {}
+
 // MetricCall tracks a single metrics call, value, and tags. All values are
 // converted to `float64` from the `int`, `float64`, or `time.Duration` inputs.
 type MetricCall struct {
+	// Type is the client method used to emit the metric, one of `Count`,
+	// `Gauge`, `Timing`, `Histogram`, or `Distribution`.
+	Type   string
 	Name   string
 	Value  float64
 	Rate   float64
 	TagMap map[string]string
+
+	// Time is when the metric was emitted, including a monotonic clock
+	// reading for reliable comparisons within a test.
+	Time time.Time
+
+	// Caller is the `file:line` of the code which emitted the metric.
+	Caller string
+}
+
+// Copy returns a deep copy of the metric call.
+func (m *MetricCall) Copy() *MetricCall {
+	c := *m
+	c.TagMap = combine(m.TagMap, nil)
+	return &c
 }
 
 // String returns a serialized representation of the metric.
@@ -60,6 +97,21 @@ func (m *MetricCall) String() string {
 type EventCall struct {
 	Event  *statsd.Event
 	TagMap map[string]string
+
+	// Time is when the event was emitted, including a monotonic clock
+	// reading for reliable comparisons within a test.
+	Time time.Time
+
+	// Caller is the `file:line` of the code which emitted the event.
+	Caller string
+}
+
+// Copy returns a deep copy of the event call.
+func (e *EventCall) Copy() *EventCall {
+	c := *e
+	c.Event = copyEvent(e.Event)
+	c.TagMap = combine(e.TagMap, nil)
+	return &c
 }
 
 // String returns a serialized representation of the event.
@@ -69,18 +121,91 @@ func (e *EventCall) String() string {
 	return fmt.Sprintf("%s:%s%v", e.Event.Title, e.Event.Text, tags)
 }
 
-// stackInfo returns a string representation of the metrics call stack.
-func stackInfo(info *callInfo) string {
-	stack := make([]string, 0, len(info.Calls))
-	for _, item := range info.Calls {
-		stack = append(stack, item.String())
+// copyEvent returns a deep copy of an event.
+func copyEvent(e *statsd.Event) *statsd.Event {
+	if e == nil {
+		return nil
+	}
+	c := *e
+	if e.Tags != nil {
+		c.Tags = make([]string, len(e.Tags))
+		copy(c.Tags, e.Tags)
+	}
+	return &c
+}
+
+// copyCall returns a deep copy of a metric or event call.
+func copyCall(call Call) Call {
+	switch t := call.(type) {
+	case *MetricCall:
+		return t.Copy()
+	case *EventCall:
+		return t.Copy()
+	}
+	return call
+}
+
+// maxStackInfo is the maximum number of calls included by `stackInfo`.
+const maxStackInfo = 50
+
+// stackInfo returns a string representation of the metrics call stack. Very
+// large stacks are truncated to keep failure output readable.
+func stackInfo(calls []Call) string {
+	stack := make([]string, 0, len(calls))
+	for i, item := range calls {
+		if i == maxStackInfo {
+			stack = append(stack, fmt.Sprintf("... and %d more", len(calls)-maxStackInfo))
+			break
+		}
+		stack = append(stack, describeCall(item))
 	}
 	return strings.Join(stack, "\n")
 }
 
+// describeCall returns the serialized representation of a call followed by
+// its call site, if known.
+func describeCall(call Call) string {
+	var caller string
+	switch t := call.(type) {
+	case *MetricCall:
+		caller = t.Caller
+	case *EventCall:
+		caller = t.Caller
+	}
+	if caller == "" {
+		return call.String()
+	}
+	return fmt.Sprintf("%s @ %s", call, caller)
+}
+
 type callInfo struct {
 	Calls   []Call
 	RWMutex sync.RWMutex
+
+	// Wants stores expectations declared via `Want` which have not yet been
+	// verified.
+	Wants []*expectation
+
+	// Strict fails verification when calls do not match any expectation.
+	Strict bool
+
+	// Registered contains the attached tests which `Verify` is registered
+	// to run for when they complete. A test is removed once that happens, so
+	// a recorder shared across tests registers with each of them.
+	Registered map[interface{}]bool
+
+	// Subscribers receive each new call as it is recorded, keyed by a unique
+	// subscription ID.
+	Subscribers      map[int]*subscriber
+	NextSubscriberID int
+
+	// Parent is the call info of the recorder a scope was created from, if
+	// any. Calls are recorded in both.
+	Parent *callInfo
+
+	// OutputWritten is set once the calls have been written on `Close` via
+	// `RecorderOutputEnv`.
+	OutputWritten bool
 }
 
 // RecorderClient records any metric that is sent, allowing you to make
@@ -136,9 +261,24 @@ type callInfo struct {
 //     }
 //   }
 //
+// Collecting Multiple Failures
+//
+// By default the first failed expectation stops the test. Use `WithErrorTest`
+// instead of `WithTest` to report every failed expectation in a single run:
+//
+//   func MyTest(t *testing.T) {
+//     recorder := metrics.NewRecorderClient().WithErrorTest(t)
+//     recorder.Count("my.metric", 1)
+//
+//     // Both of these are reported.
+//     recorder.Expect("my.metric").Value(2)
+//     recorder.Expect("other.metric")
+//   }
+//
 type RecorderClient struct {
 	callInfo *callInfo
 	test     TestFailer
+	errorer  TestErrorer
 	rate     float64
 	tagMap   map[string]string
 }
@@ -157,6 +297,7 @@ func (c *RecorderClient) WithTags(tags map[string]string) Client {
 	return &RecorderClient{
 		callInfo: c.callInfo,
 		test:     c.test,
+		errorer:  c.errorer,
 		rate:     c.rate,
 		tagMap:   combine(c.tagMap, tags),
 	}
@@ -167,6 +308,7 @@ func (c *RecorderClient) WithRate(rate float64) Client {
 	return &RecorderClient{
 		callInfo: c.callInfo,
 		test:     c.test,
+		errorer:  c.errorer,
 		rate:     rate,
 		tagMap:   c.tagMap,
 	}
@@ -174,33 +316,132 @@ func (c *RecorderClient) WithRate(rate float64) Client {
 
 // WithTest returns a recorder client linked with a given test instance.
 func (c *RecorderClient) WithTest(test TestFailer) *RecorderClient {
-	return &RecorderClient{
+	client := &RecorderClient{
 		callInfo: c.callInfo,
 		test:     test,
 		rate:     c.rate,
 		tagMap:   c.tagMap,
 	}
+	client.linkVerify()
+	return client
+}
+
+// Scope returns a child recorder with its own call info. Calls made through
+// the scope (or its clones) are visible to queries on both the scope and this
+// recorder, while queries on the scope only see calls made through it.
+// `Reset` on a scope only clears the scope's calls. This is useful for
+// parallel subtests sharing a recorder:
+//
+//   t.Run("sub", func(t *testing.T) {
+//     t.Parallel()
+//     scope := recorder.Scope().WithTest(t)
+//     handler(scope)
+//     scope.Expect("my.metric").Value(1)
+//   })
+func (c *RecorderClient) Scope() *RecorderClient {
+	return &RecorderClient{
+		callInfo: &callInfo{Parent: c.callInfo},
+		test:     c.test,
+		errorer:  c.errorer,
+		rate:     c.rate,
+		tagMap:   c.tagMap,
+	}
+}
+
+// WithErrorTest returns a recorder client linked with a given test instance
+// that reports failed expectations via `Errorf`, which does not stop the
+// test. This allows several failed expectations to be reported at once.
+func (c *RecorderClient) WithErrorTest(test TestErrorer) *RecorderClient {
+	client := &RecorderClient{
+		callInfo: c.callInfo,
+		errorer:  test,
+		rate:     c.rate,
+		tagMap:   c.tagMap,
+	}
+	client.linkVerify()
+	return client
+}
+
+// linkVerify registers `Verify` with a newly attached test when strict mode
+// is enabled or expectations were declared before the test was attached.
+func (c *RecorderClient) linkVerify() {
+	c.callInfo.RWMutex.Lock()
+	defer c.callInfo.RWMutex.Unlock()
+	if c.callInfo.Strict || len(c.callInfo.Wants) > 0 {
+		c.registerVerify()
+	}
+}
+
+// helper returns the attached test if it supports marking helper functions.
+func (c *RecorderClient) helper() helper {
+	if h, ok := c.errorer.(helper); ok {
+		return h
+	}
+	if h, ok := c.test.(helper); ok {
+		return h
+	}
+	return noHelper{}
 }
 
 // logCall will record a single metrics call.
-func (c *RecorderClient) logCall(name string, value interface{}) {
+func (c *RecorderClient) logCall(t string, name string, value interface{}) {
 	tagMapCopy := make(map[string]string, len(c.tagMap))
 	for k, v := range c.tagMap {
 		tagMapCopy[k] = v
 	}
-	c.callInfo.RWMutex.Lock()
-	defer c.callInfo.RWMutex.Unlock()
-	c.callInfo.Calls = append(c.callInfo.Calls, &MetricCall{
+	call := &MetricCall{
+		Type:   t,
 		Name:   name,
 		Value:  toFloat64(value),
 		Rate:   c.rate,
 		TagMap: tagMapCopy,
-	})
+		Time:   time.Now(),
+		Caller: callSite(),
+	}
+	c.record(call)
+}
+
+// record stores a call and delivers it to any subscribers. Subscribers are
+// notified after the lock is released so that they may safely query the
+// recorder or emit metrics themselves.
+//
+// Calls made through a scope are also recorded by each of its parents.
+func (c *RecorderClient) record(call Call) {
+	var subscribers []*subscriber
+	for info := c.callInfo; info != nil; info = info.Parent {
+		info.RWMutex.Lock()
+		info.Calls = append(info.Calls, call)
+		for _, s := range info.Subscribers {
+			subscribers = append(subscribers, s)
+		}
+		info.RWMutex.Unlock()
+	}
+
+	for _, s := range subscribers {
+		s.deliver(call)
+	}
 }
 
-// Close on the RecorderClient is a no-op
+// Close on the RecorderClient is a no-op, unless the `METRICS_RECORDER_OUTPUT`
+// environment variable is set, in which case the recorded calls are written
+// to that path as JSON. See `RecorderOutputEnv`. Only the first `Close` of a
+// root recorder or one of its clones writes the calls; closing a scope never
+// does, since it only sees its own calls.
 func (c *RecorderClient) Close() error {
-	return nil
+	path := os.Getenv(RecorderOutputEnv)
+	if path == "" || c.callInfo.Parent != nil {
+		return nil
+	}
+
+	c.callInfo.RWMutex.Lock()
+	written := c.callInfo.OutputWritten
+	c.callInfo.OutputWritten = true
+	c.callInfo.RWMutex.Unlock()
+
+	if written {
+		return nil
+	}
+	return c.WriteJSONFile(path)
 }
 
 // Count adds some value to a metric.
@@ -208,7 +449,7 @@ func (c *RecorderClient) Count(name string, value int64) {
 	// Normally this would be stored as an integer, but instead we assert that
 	// it can be cast to an int, cast it, and then store it as a float so that
 	// assertions below are simpler.
-	c.logCall(name, value)
+	c.logCall("Count", name, value)
 }
 
 // Incr adds one to a metric.
@@ -223,7 +464,7 @@ func (c *RecorderClient) Decr(name string) {
 
 // Gauge sets a numeric value.
 func (c *RecorderClient) Gauge(name string, value float64) {
-	c.logCall(name, value)
+	c.logCall("Gauge", name, value)
 }
 
 // Event tracks an event that may be relevant to other metrics.
@@ -232,30 +473,33 @@ func (c *RecorderClient) Event(e *statsd.Event) {
 	for k, v := range c.tagMap {
 		tagMapCopy[k] = v
 	}
-	c.callInfo.RWMutex.Lock()
-	defer c.callInfo.RWMutex.Unlock()
-	c.callInfo.Calls = append(c.callInfo.Calls, &EventCall{
-		Event:  e,
+	call := &EventCall{
+		// Copy the event so later changes by the caller are not recorded.
+		Event:  copyEvent(e),
 		TagMap: tagMapCopy,
-	})
+		Time:   time.Now(),
+		Caller: callSite(),
+	}
+	c.record(call)
 }
 
 // Timing tracks a duration.
 func (c *RecorderClient) Timing(name string, value time.Duration) {
-	c.logCall(name, value)
+	c.logCall("Timing", name, value)
 }
 
 // Histogram sets a numeric value while tracking min/max/avg/p95/etc.
 func (c *RecorderClient) Histogram(name string, value float64) {
-	c.logCall(name, value)
+	c.logCall("Histogram", name, value)
 }
 
 // Distribution tracks the statistical distribution of a set of values.
 func (c *RecorderClient) Distribution(name string, value float64) {
-	c.logCall(name, value)
+	c.logCall("Distribution", name, value)
 }
 
 // Reset will clear the call info context, which is useful between test runs.
+// On a scope created via `Scope`, only the scope's calls are cleared.
 func (c *RecorderClient) Reset() {
 	c.callInfo.RWMutex.Lock()
 	defer c.callInfo.RWMutex.Unlock()
@@ -273,21 +517,43 @@ func (c *RecorderClient) Length() int {
 // Fatalf fails whatever test is attached to this recorder and additionally
 // appends the current metrics call stack and calling information to the
 // output message to help with debugging.
+//
+// When the recorder was linked via `WithErrorTest`, the failure is reported
+// via `Errorf` and the test continues.
 func (c *RecorderClient) Fatalf(format string, args ...interface{}) {
-	if c.test == nil {
+	c.helper().Helper()
+	if c.test == nil && c.errorer == nil {
 		panic("No test associated with metrics recorder, you must call `recorder.WithTest(t)`")
 	}
-	// blacklist contains a set of fully qualified function name components that
-	// we will filter out to keep the call stack concise.
-	blacklist := []string{
-		"github.com/istreamlabs/go-metrics/metrics.",
-		"testing.tRunner",
-		"runtime.goexit",
-	}
 	buf := "\nFrom call stack:\n"
+	for _, frame := range externalFrames(2) {
+		blurb := getBlurb(frame.File, frame.Line)
+		buf += fmt.Sprintf("%s %s:%d\n\t%s\n", frame.Function, path.Base(frame.File), frame.Line, blurb)
+	}
+
+	args = append(args, stackInfo(c.callsCopy()), buf)
+	if c.errorer != nil {
+		c.errorer.Errorf(format+" Current metrics stack:\n%s%s", args...)
+		return
+	}
+	c.test.Fatalf(format+" Current metrics stack:\n%s%s", args...)
+}
+
+// blacklist contains a set of fully qualified function name components that
+// we will filter out to keep the call stack concise.
+var blacklist = []string{
+	"github.com/istreamlabs/go-metrics/metrics.",
+	"testing.tRunner",
+	"runtime.goexit",
+}
+
+// externalFrames returns the frames of the current call stack, skipping
+// `skip` frames and any frames matching the blacklist.
+func externalFrames(skip int) []runtime.Frame {
+	var result []runtime.Frame
 	callers := make([]uintptr, 10)
-	runtime.Callers(1, callers)
-	frames := runtime.CallersFrames(callers)
+	n := runtime.Callers(skip+1, callers)
+	frames := runtime.CallersFrames(callers[:n])
 PRINT_FRAMES:
 	for {
 		frame, more := frames.Next()
@@ -297,28 +563,63 @@ PRINT_FRAMES:
 			}
 		}
 		if frame.Function != "" {
-			blurb := getBlurb(frame.File, frame.Line)
-			buf += fmt.Sprintf("%s %s:%d\n\t%s\n", frame.Function, path.Base(frame.File), frame.Line, blurb)
+			result = append(result, frame)
 		}
 		if !more {
 			break
 		}
 	}
+	return result
+}
 
-	args = append(args, stackInfo(c.callInfo), buf)
-	c.test.Fatalf(format+" Current metrics stack:\n%s%s", args...)
+// callSite returns the `file:line` of the first caller outside of this
+// package, or an empty string if it cannot be determined.
+func callSite() string {
+	frames := externalFrames(2)
+	if len(frames) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d", path.Base(frames[0].File), frames[0].Line)
 }
 
-// GetCalls returns a slice of all recorded calls.
+// GetCalls returns a slice of all recorded calls. The slice itself is a copy
+// and is safe to use while other goroutines emit metrics, but the calls it
+// contains are shared with the recorder and must not be modified. Use
+// `CopyCalls` if you need to modify them.
 func (c *RecorderClient) GetCalls() []Call {
-	return c.callInfo.Calls
+	return c.callsCopy()
 }
 
-// ExpectEmpty asserts that no metrics have been emitted.
-func (c *RecorderClient) ExpectEmpty() {
+// CopyCalls returns a deep copy of all recorded calls, including their tag
+// maps and events, which can be freely modified or retained.
+func (c *RecorderClient) CopyCalls() []Call {
 	c.callInfo.RWMutex.RLock()
 	defer c.callInfo.RWMutex.RUnlock()
-	if len(c.callInfo.Calls) > 0 {
+	calls := make([]Call, len(c.callInfo.Calls))
+	for i, call := range c.callInfo.Calls {
+		calls[i] = copyCall(call)
+	}
+	return calls
+}
+
+// EachCall calls `f` for each recorded call in order, stopping early if `f`
+// returns false. This avoids copying large recordings. The recorder is
+// locked for reading while iterating, so `f` must not emit metrics to this
+// recorder or its clones, and must not modify or retain the calls.
+func (c *RecorderClient) EachCall(f func(Call) bool) {
+	c.callInfo.RWMutex.RLock()
+	defer c.callInfo.RWMutex.RUnlock()
+	for _, call := range c.callInfo.Calls {
+		if !f(call) {
+			return
+		}
+	}
+}
+
+// ExpectEmpty asserts that no metrics have been emitted.
+func (c *RecorderClient) ExpectEmpty() {
+	c.helper().Helper()
+	if c.Length() > 0 {
 		c.Fatalf("Expected empty metrics call stack.")
 	}
 }
@@ -334,6 +635,19 @@ func (c *RecorderClient) callsCopy() []Call {
 	return calls
 }
 
+// newQuery creates a query over a copy of the currently recorded calls.
+func (c *RecorderClient) newQuery(checkMin bool) *query {
+	calls := c.callsCopy()
+	return &query{
+		calls:    calls,
+		all:      calls,
+		test:     c,
+		helper:   c.helper(),
+		minCalls: 1,
+		checkMin: checkMin,
+	}
+}
+
 // Expect finds metrics (by name) or events (by title) and returns the
 // matching calls. A wildcard `*` character will match any ID. This method does
 // *not* remove the call from the recorded call list.
@@ -344,12 +658,8 @@ func (c *RecorderClient) callsCopy() []Call {
 //   // Get an event by its title.
 //   recorder.Expect("my.event")
 func (c *RecorderClient) Expect(id string) Query {
-	return (&query{
-		calls:    c.callsCopy(),
-		test:     c,
-		minCalls: 1,
-		checkMin: true,
-	}).ID(id)
+	c.helper().Helper()
+	return c.newQuery(true).ID(id)
 }
 
 // ExpectContains finds metrics or events that contain the `component` in their
@@ -364,12 +674,8 @@ func (c *RecorderClient) Expect(id string) Query {
 //
 // See `Call.String()` for the serialization format.
 func (c *RecorderClient) ExpectContains(component string) Query {
-	return (&query{
-		calls:    c.callsCopy(),
-		test:     c,
-		minCalls: 1,
-		checkMin: true,
-	}).Contains(component)
+	c.helper().Helper()
+	return c.newQuery(true).Contains(component)
 }
 
 // If acts like `Expect`, but doesn't check for the minimum number of calls
@@ -389,10 +695,6 @@ func (c *RecorderClient) ExpectContains(component string) Query {
 //   recorder.Expect("my.metric")
 //   recorder.If("my.metric").Accept()
 func (c *RecorderClient) If(id string) Query {
-	return (&query{
-		calls:    c.callsCopy(),
-		test:     c,
-		minCalls: 1,
-		checkMin: false,
-	}).ID(id)
+	c.helper().Helper()
+	return c.newQuery(false).ID(id)
 }
@@ -3,6 +3,7 @@ package metrics_test
 import (
 	"fmt"
 	"reflect"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -28,6 +29,16 @@ func (ft *fakeTest) Fatalf(format string, args ...interface{}) {
 	panic(fmt.Sprintf(format, args...))
 }
 
+// messageTest records the last failure message instead of failing.
+type messageTest struct {
+	message string
+}
+
+// Fatalf stores the formatted failure message.
+func (mt *messageTest) Fatalf(format string, args ...interface{}) {
+	mt.message = fmt.Sprintf(format, args...)
+}
+
 // ExpectFailure will call a given function with a fake test and recording
 // metrics client, then catch any panic which occurs from the fake test having
 // its `Fatalf` method called.
@@ -317,3 +328,473 @@ func TestRecorderWithRate(t *testing.T) {
 	recorder.If("sampled").Rate(1.0).Reject()
 	recorder.Expect("sampled").Rate(0.1)
 }
+
+func TestRecorderExactTags(t *testing.T) {
+	recorder := metrics.NewRecorderClient().WithTest(t)
+	recorder.WithTags(map[string]string{
+		"env":    "prod",
+		"status": "200",
+	}).Incr("requests")
+
+	recorder.Expect("requests").Tags(map[string]string{"env": "prod", "status": "200"})
+	recorder.Expect("requests").OnlyTagNames("env", "status", "region")
+	recorder.Expect("requests").NoTagName("user")
+	recorder.Expect("requests").TagMatch("status", "^2[0-9]{2}$")
+
+	recorder.If("requests").Tags(map[string]string{"env": "prod"}).Reject()
+	recorder.If("requests").OnlyTagNames("env").Reject()
+	recorder.If("requests").NoTagName("env").Reject()
+	recorder.If("requests").TagMatch("status", "^5").Reject()
+
+	ExpectFailure(t, "Expecting exact tags should fail with extra tags",
+		func(r *metrics.RecorderClient) {
+			r.WithTags(map[string]string{"env": "prod", "user": "1234"}).Incr("requests")
+			r.Expect("requests").Tags(map[string]string{"env": "prod"})
+		})
+
+	ExpectFailure(t, "Expecting only allowed tag names should fail with extra tags",
+		func(r *metrics.RecorderClient) {
+			r.WithTags(map[string]string{"env": "prod", "user": "1234"}).Incr("requests")
+			r.Expect("requests").OnlyTagNames("env")
+		})
+
+	ExpectFailure(t, "Expecting a missing tag should fail when present",
+		func(r *metrics.RecorderClient) {
+			r.WithTags(map[string]string{"user": "1234"}).Incr("requests")
+			r.Expect("requests").NoTagName("user")
+		})
+
+	ExpectFailure(t, "Expecting a tag value pattern should fail when not matched",
+		func(r *metrics.RecorderClient) {
+			r.WithTags(map[string]string{"status": "503"}).Incr("requests")
+			r.Expect("requests").TagMatch("status", "^2")
+		})
+}
+
+func TestRecorderExactTagsDiff(t *testing.T) {
+	failer := &messageTest{}
+	recorder := metrics.NewRecorderClient().WithTest(failer)
+	recorder.WithTags(map[string]string{"env": "staging", "user": "1234"}).Incr("requests")
+
+	recorder.Expect("requests").Tags(map[string]string{"env": "prod"})
+
+	expected := "': name matched, tags expected [env:prod], actual [env:staging user:1234] (env was 'staging' not 'prod', unexpected user:1234)"
+	if !strings.Contains(failer.message, expected) {
+		t.Fatalf("Expected failure message to contain %q but got %q", expected, failer.message)
+	}
+}
+
+func TestRecorderNearestDiff(t *testing.T) {
+	failer := &messageTest{}
+	recorder := metrics.NewRecorderClient().WithTest(failer)
+	for i := 0; i < 100; i++ {
+		recorder.Incr(fmt.Sprintf("unrelated.%d", i))
+	}
+	recorder.WithTags(map[string]string{"env": "prod"}).Count("requests", 2)
+	recorder.WithTags(map[string]string{"env": "staging"}).Count("request", 1)
+
+	recorder.If("requests").Tag("env", "staging").Value(1).Accept()
+
+	for _, expected := range []string{
+		"Nearest calls:",
+		"'requests:2[env:prod] @ recorder_test.go:",
+		"': name matched, tag env was 'prod' not 'staging', value 2 not 1",
+		"'request:1[env:staging] @ recorder_test.go:",
+		"': name was 'request' not 'requests', tag env matched, value matched",
+		"... and 52 more",
+	} {
+		if !strings.Contains(failer.message, expected) {
+			t.Fatalf("Expected failure message to contain %q but got %q", expected, failer.message)
+		}
+	}
+}
+
+// errorTest records failure messages without stopping.
+type errorTest struct {
+	messages []string
+	helpers  int
+}
+
+// Errorf stores the formatted failure message.
+func (et *errorTest) Errorf(format string, args ...interface{}) {
+	et.messages = append(et.messages, fmt.Sprintf(format, args...))
+}
+
+// Helper counts how many times a function was marked as a helper.
+func (et *errorTest) Helper() {
+	et.helpers++
+}
+
+func TestRecorderWithErrorTest(t *testing.T) {
+	failer := &errorTest{}
+	recorder := metrics.NewRecorderClient().WithErrorTest(failer)
+	recorder.WithTags(map[string]string{"env": "prod"}).Count("my.metric", 1)
+
+	// Each failing query is reported once, even when later filters in the
+	// same chain would also fail.
+	recorder.Expect("my.metric").Value(2).Tag("env", "staging")
+	recorder.Expect("other.metric")
+	recorder.If("my.metric").Reject()
+	recorder.Expect("my.metric").Value(1).Tag("env", "prod")
+
+	if len(failer.messages) != 3 {
+		t.Fatalf("Expected 3 failures but got %d: %v", len(failer.messages), failer.messages)
+	}
+	if failer.helpers == 0 {
+		t.Fatalf("Expected recorder to mark helper functions")
+	}
+
+	// Clones keep reporting through the same test.
+	recorder.WithRate(0.5).(*metrics.RecorderClient).ExpectEmpty()
+	if len(failer.messages) != 4 {
+		t.Fatalf("Expected 4 failures but got %d", len(failer.messages))
+	}
+}
+
+// cleanupTest records failures and cleanup functions so they can be run
+// manually.
+type cleanupTest struct {
+	errorTest
+	cleanups []func()
+}
+
+// Cleanup stores a function to run when the test completes.
+func (ct *cleanupTest) Cleanup(f func()) {
+	ct.cleanups = append(ct.cleanups, f)
+}
+
+// finish runs the registered cleanup functions.
+func (ct *cleanupTest) finish() {
+	for _, f := range ct.cleanups {
+		f()
+	}
+}
+
+func TestRecorderWant(t *testing.T) {
+	recorder := metrics.NewRecorderClient().WithTest(t)
+	recorder.Want("requests").Value(1).Tag("env", "prod")
+	recorder.Want("errors").Reject()
+
+	recorder.WithTags(map[string]string{"env": "prod"}).Incr("requests")
+}
+
+func TestRecorderWantFailures(t *testing.T) {
+	failer := &cleanupTest{}
+	recorder := metrics.NewRecorderClient().WithErrorTest(failer)
+	recorder.Want("requests").Value(2)
+	recorder.Want("requests").Value(1)
+	recorder.WithTags(map[string]string{"env": "prod"}).(*metrics.RecorderClient).Want("errors").Reject()
+
+	recorder.Incr("requests")
+	recorder.Incr("errors")
+
+	if len(failer.cleanups) != 1 {
+		t.Fatalf("Expected a single cleanup function but got %d", len(failer.cleanups))
+	}
+	if len(failer.messages) != 0 {
+		t.Fatalf("Expected no failures before cleanup but got %v", failer.messages)
+	}
+
+	failer.finish()
+	if len(failer.messages) != 2 {
+		t.Fatalf("Expected 2 failures but got %d: %v", len(failer.messages), failer.messages)
+	}
+
+	// Expectations are only verified once.
+	recorder.Verify()
+	if len(failer.messages) != 2 {
+		t.Fatalf("Expected verified expectations to be cleared but got %v", failer.messages)
+	}
+}
+
+func TestRecorderWantStrict(t *testing.T) {
+	failer := &cleanupTest{}
+	recorder := metrics.NewRecorderClient().WithErrorTest(failer).Strict()
+	recorder.Want("requests").MinTimes(2)
+
+	recorder.Incr("requests")
+	recorder.Incr("requests")
+	recorder.Incr("unexpected")
+
+	recorder.Verify()
+	if len(failer.messages) != 1 || !strings.Contains(failer.messages[0], "Unexpected metrics 'unexpected:1[]'") {
+		t.Fatalf("Expected unexpected metric failure but got %v", failer.messages)
+	}
+}
+
+func TestRecorderCallTimeAndSite(t *testing.T) {
+	recorder := metrics.NewRecorderClient().WithTest(t)
+	recorder.Incr("before")
+	start := time.Now()
+	recorder.Incr("after")
+	recorder.Event(statsd.NewEvent("event", "text"))
+	end := time.Now()
+
+	recorder.Expect("after").After(start).Before(end)
+	recorder.Expect("event").After(start)
+	recorder.If("before").After(start).Reject()
+	recorder.If("after").Before(start).Reject()
+
+	call := recorder.Expect("after").GetCalls()[0].(*metrics.MetricCall)
+	if !strings.HasPrefix(call.Caller, "recorder_test.go:") {
+		t.Fatalf("Expected call site in recorder_test.go but got '%s'", call.Caller)
+	}
+	if call.Time.Before(start) || call.Time.After(end) {
+		t.Fatalf("Expected call time between %v and %v but got %v", start, end, call.Time)
+	}
+
+	event := recorder.Expect("event").GetCalls()[0].(*metrics.EventCall)
+	if !strings.HasPrefix(event.Caller, "recorder_test.go:") {
+		t.Fatalf("Expected call site in recorder_test.go but got '%s'", event.Caller)
+	}
+}
+
+func TestRecorderCopyCalls(t *testing.T) {
+	recorder := metrics.NewRecorderClient().WithTest(t)
+	recorder.WithTags(map[string]string{"env": "prod"}).Incr("requests")
+	event := statsd.NewEvent("deploy", "v1")
+	event.Tags = []string{"team:video"}
+	recorder.Event(event)
+
+	// Changes by the caller after emitting are not recorded.
+	event.Text = "v2"
+	recorder.Expect("deploy").Text("v1")
+
+	calls := recorder.CopyCalls()
+	calls[0].(*metrics.MetricCall).TagMap["env"] = "staging"
+	calls[1].(*metrics.EventCall).Event.Tags[0] = "team:audio"
+
+	recorder.Expect("requests").Tag("env", "prod")
+	original := recorder.GetCalls()[1].(*metrics.EventCall)
+	ExpectEqual(t, []string{"team:video"}, original.Event.Tags)
+}
+
+func TestRecorderEachCall(t *testing.T) {
+	recorder := metrics.NewRecorderClient().WithTest(t)
+	recorder.Incr("one")
+	recorder.Incr("two")
+	recorder.Incr("three")
+
+	var names []string
+	recorder.EachCall(func(call metrics.Call) bool {
+		names = append(names, call.(*metrics.MetricCall).Name)
+		return len(names) < 2
+	})
+	ExpectEqual(t, []string{"one", "two"}, names)
+}
+
+func TestRecorderConcurrentReads(t *testing.T) {
+	recorder := metrics.NewRecorderClient().WithTest(t)
+
+	// Read while other goroutines are still emitting. Run with `-race` to
+	// detect unsynchronized access.
+	wg := sync.WaitGroup{}
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				recorder.WithTags(map[string]string{"tag": "value"}).Incr("test.concurrency")
+			}
+		}()
+	}
+	for i := 0; i < 10; i++ {
+		recorder.GetCalls()
+		recorder.CopyCalls()
+		recorder.EachCall(func(call metrics.Call) bool { return true })
+	}
+	wg.Wait()
+
+	recorder.Expect("test.concurrency").MinTimes(300)
+}
+
+func TestRecorderScope(t *testing.T) {
+	recorder := metrics.NewRecorderClient().WithTest(t)
+	recorder.Incr("parent")
+
+	for _, name := range []string{"one", "two"} {
+		name := name
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			scope := recorder.Scope().WithTest(t)
+			scope.WithTags(map[string]string{"scope": name}).Incr("child")
+
+			scope.Expect("child").Tags(map[string]string{"scope": name}).MinTimes(1)
+			scope.If("child").MinTimes(2).Reject()
+			scope.If("parent").Reject()
+		})
+	}
+
+	t.Run("nested", func(t *testing.T) {
+		scope := recorder.Scope().WithTest(t)
+		nested := scope.Scope()
+		nested.Incr("nested")
+		scope.Expect("nested")
+
+		scope.Reset()
+		ExpectEqual(t, 0, scope.Length())
+		ExpectEqual(t, 1, nested.Length())
+		recorder.Expect("nested")
+	})
+}
+
+func TestRecorderScopeSeenByParent(t *testing.T) {
+	recorder := metrics.NewRecorderClient().WithTest(t)
+
+	var parentCalls int
+	unsubscribe := recorder.Subscribe(func(metrics.Call) { parentCalls++ }, nil)
+	defer unsubscribe()
+
+	scope := recorder.Scope()
+	scope.Incr("child")
+	scope.Incr("child")
+
+	recorder.Expect("child").MinTimes(2)
+	ExpectEqual(t, 2, parentCalls)
+}
+
+func TestRecorderLogicalCombinators(t *testing.T) {
+	recorder := metrics.NewRecorderClient().WithTest(t)
+	recorder.WithTags(map[string]string{"env": "prod", "status": "500"}).Incr("requests")
+	recorder.WithTags(map[string]string{"env": "staging", "status": "503"}).Incr("requests")
+	recorder.WithTags(map[string]string{"env": "staging", "status": "200"}).Count("requests", 5)
+
+	recorder.Expect("requests").Or(
+		metrics.Match().Tag("status", "500"),
+		metrics.Match().Tag("status", "503"),
+	).MinTimes(2)
+	recorder.Expect("requests").Not(metrics.Match().Tag("env", "prod")).MinTimes(2)
+	recorder.Expect("requests").Where("value above 1", func(call metrics.Call) bool {
+		return call.(*metrics.MetricCall).Value > 1
+	}).Tag("status", "200")
+	recorder.If("requests").Not(metrics.Match().ID("requests")).Reject()
+	recorder.Want("requests").Or(metrics.Match().Tag("status", "500")).Not(metrics.Match().Tag("env", "staging"))
+
+	failer := &messageTest{}
+	failing := metrics.NewRecorderClient().WithTest(failer)
+	failing.WithTags(map[string]string{"status": "404"}).Incr("requests")
+	failing.Expect("requests").Or(
+		metrics.Match().Tag("status", "500"),
+		metrics.Match().Tag("status", "503"),
+	)
+
+	expected := "Query was 'id(requests) or(tag(status, 500) | tag(status, 503))'"
+	if !strings.Contains(failer.message, expected) {
+		t.Fatalf("Expected failure message to contain %q but got %q", expected, failer.message)
+	}
+
+	ExpectFailure(t, "Expecting a negated match should fail when all calls match",
+		func(r *metrics.RecorderClient) {
+			r.WithTags(map[string]string{"env": "prod"}).Incr("requests")
+			r.Expect("requests").Not(metrics.Match().Tag("env", "prod"))
+		})
+
+	ExpectFailure(t, "Expecting a predicate should fail when no calls match",
+		func(r *metrics.RecorderClient) {
+			r.Incr("requests")
+			r.Expect("requests").Where("never", func(metrics.Call) bool { return false })
+		})
+}
+
+func TestRecorderEventFields(t *testing.T) {
+	recorder := metrics.NewRecorderClient().WithTest(t)
+	now := time.Now()
+	recorder.Event(&statsd.Event{
+		Title:          "deploy.failed",
+		Text:           "rollback",
+		Timestamp:      now,
+		Hostname:       "host-1",
+		AggregationKey: "deploy",
+		Priority:       statsd.Normal,
+		SourceTypeName: "ci",
+		AlertType:      statsd.Error,
+	})
+	recorder.Incr("deploy.failed")
+
+	recorder.Expect("deploy.failed").
+		AlertType(statsd.Error).
+		Priority(statsd.Normal).
+		AggregationKey("deploy").
+		SourceTypeName("ci").
+		Hostname("host-1").
+		Timestamp(now.Add(-time.Minute), now.Add(time.Minute))
+	recorder.If("deploy.failed").AlertType(statsd.Info).Reject()
+	recorder.If("deploy.failed").Priority(statsd.Low).Reject()
+	recorder.If("deploy.failed").Timestamp(now.Add(time.Second), now.Add(time.Minute)).Reject()
+
+	// Events created via `statsd.NewEvent` have an empty priority and alert
+	// type, which DogStatsD treats as normal and info.
+	recorder.Event(statsd.NewEvent("deploy.started", "v2"))
+	recorder.Expect("deploy.started").Priority(statsd.Normal).AlertType(statsd.Info)
+	recorder.If("deploy.started").Priority(statsd.Low).Reject()
+	recorder.If("deploy.started").AlertType(statsd.Error).Reject()
+
+	ExpectFailure(t, "Expecting an error event should fail when downgraded to info",
+		func(r *metrics.RecorderClient) {
+			r.Event(&statsd.Event{Title: "deploy.failed", AlertType: statsd.Info})
+			r.Expect("deploy.failed").AlertType(statsd.Error)
+		})
+}
+
+func TestRecorderStrictWithoutWant(t *testing.T) {
+	failer := &cleanupTest{}
+	recorder := metrics.NewRecorderClient().WithErrorTest(failer).Strict()
+	recorder.Incr("unexpected")
+
+	failer.finish()
+	if len(failer.messages) != 1 || !strings.Contains(failer.messages[0], "Unexpected metrics 'unexpected:1[]'") {
+		t.Fatalf("Expected unexpected metric failure but got %v", failer.messages)
+	}
+
+	// Strict mode enabled before attaching a test is verified too.
+	failer = &cleanupTest{}
+	recorder = metrics.NewRecorderClient().Strict().WithErrorTest(failer)
+	recorder.Incr("unexpected")
+
+	if len(failer.cleanups) != 1 {
+		t.Fatalf("Expected a single cleanup function but got %d", len(failer.cleanups))
+	}
+	failer.finish()
+	if len(failer.messages) != 1 {
+		t.Fatalf("Expected 1 failure but got %v", failer.messages)
+	}
+}
+
+func TestRecorderWantSharedAcrossTests(t *testing.T) {
+	shared := metrics.NewRecorderClient()
+
+	first := &cleanupTest{}
+	shared.WithErrorTest(first).Want("first")
+	shared.Incr("first")
+	first.finish()
+
+	// A second test using the same recorder gets its own verification.
+	second := &cleanupTest{}
+	shared.WithErrorTest(second).Want("second")
+	if len(second.cleanups) != 1 {
+		t.Fatalf("Expected a cleanup function for the second test but got %d", len(second.cleanups))
+	}
+	second.finish()
+
+	if len(first.messages) != 0 {
+		t.Fatalf("Expected no failures in the first test but got %v", first.messages)
+	}
+	if len(second.messages) != 1 {
+		t.Fatalf("Expected the unmet expectation to fail the second test but got %v", second.messages)
+	}
+}
+
+func TestRecorderWantBeforeTest(t *testing.T) {
+	recorder := metrics.NewRecorderClient()
+	recorder.Want("requests")
+
+	failer := &cleanupTest{}
+	recorder.WithErrorTest(failer)
+	if len(failer.cleanups) != 1 {
+		t.Fatalf("Expected a cleanup function but got %d", len(failer.cleanups))
+	}
+	failer.finish()
+	if len(failer.messages) != 1 {
+		t.Fatalf("Expected the unmet expectation to fail but got %v", failer.messages)
+	}
+}
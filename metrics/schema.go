@@ -0,0 +1,246 @@
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/istreamlabs/go-metrics/internal/levenshtein"
+	"gopkg.in/yaml.v3"
+)
+
+// schemaTypes lists the metric types which can be declared in a schema.
+var schemaTypes = []string{"Count", "Gauge", "Timing", "Histogram", "Distribution"}
+
+// MetricTypes returns the metric types which can be declared in a schema.
+// They match the values of `MetricCall.Type`, with `Incr` and `Decr` emitting
+// a `Count`.
+func MetricTypes() []string {
+	return append([]string{}, schemaTypes...)
+}
+
+// MetricSchema declares a single metric.
+type MetricSchema struct {
+	// Name of the metric as passed to the client, e.g. `requests.count`.
+	Name string `json:"name" yaml:"name"`
+
+	// Type of the metric, one of `MetricTypes()`.
+	Type string `json:"type" yaml:"type"`
+
+	// Unit and Description document the metric.
+	Unit        string `json:"unit,omitempty" yaml:"unit,omitempty"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+
+	// Tags maps each allowed tag key to its allowed values. An empty list of
+	// values allows any value for that key. Keys which are not present are
+	// not allowed.
+	Tags map[string][]string `json:"tags,omitempty" yaml:"tags,omitempty"`
+}
+
+// Registry contains declared metrics which calls can be validated against.
+// It is safe for concurrent use. Declare metrics in Go:
+//
+//   registry, err := metrics.NewRegistry(
+//     metrics.MetricSchema{
+//       Name: "requests.count",
+//       Type: "Count",
+//       Unit: "request",
+//       Description: "Number of handled HTTP requests.",
+//       Tags: map[string][]string{
+//         "method": {"GET", "POST"},
+//         "status": nil,
+//       },
+//     },
+//   )
+//
+// Or in a YAML or JSON file loaded via `LoadRegistryFile`:
+//
+//   metrics:
+//     - name: requests.count
+//       type: Count
+//       tags:
+//         method: [GET, POST]
+//         status: []
+type Registry struct {
+	lock    sync.RWMutex
+	metrics map[string]MetricSchema
+}
+
+// registryFile is the serialized form of a registry.
+type registryFile struct {
+	Metrics []MetricSchema `json:"metrics" yaml:"metrics"`
+}
+
+// NewRegistry creates a new registry with the given metrics declared.
+func NewRegistry(schemas ...MetricSchema) (*Registry, error) {
+	r := &Registry{metrics: make(map[string]MetricSchema)}
+	if err := r.Register(schemas...); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// LoadRegistry reads a registry from JSON.
+func LoadRegistry(reader io.Reader) (*Registry, error) {
+	var declared registryFile
+	if err := json.NewDecoder(reader).Decode(&declared); err != nil {
+		return nil, err
+	}
+	return NewRegistry(declared.Metrics...)
+}
+
+// LoadRegistryYAML reads a registry from YAML.
+func LoadRegistryYAML(reader io.Reader) (*Registry, error) {
+	var declared registryFile
+	if err := yaml.NewDecoder(reader).Decode(&declared); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return NewRegistry(declared.Metrics...)
+}
+
+// LoadRegistryFile reads a registry from a file. Files ending in `.yaml` or
+// `.yml` are read as YAML, and all others as JSON.
+func LoadRegistryFile(path string) (*Registry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return LoadRegistryYAML(f)
+	}
+	return LoadRegistry(f)
+}
+
+// Register declares additional metrics. Names must be unique.
+func (r *Registry) Register(schemas ...MetricSchema) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for _, schema := range schemas {
+		if schema.Name == "" {
+			return fmt.Errorf("metric schema is missing a name")
+		}
+		if _, ok := r.metrics[schema.Name]; ok {
+			return fmt.Errorf("metric '%s' is already registered", schema.Name)
+		}
+		known := false
+		for _, t := range schemaTypes {
+			if schema.Type == t {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("metric '%s' has unknown type '%s', expected one of %s", schema.Name, schema.Type, strings.Join(schemaTypes, ", "))
+		}
+		r.metrics[schema.Name] = schema
+	}
+
+	return nil
+}
+
+// Lookup returns the declared metric with the given name.
+func (r *Registry) Lookup(name string) (MetricSchema, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	schema, ok := r.metrics[name]
+	return schema, ok
+}
+
+// Schemas returns all declared metrics sorted by name.
+func (r *Registry) Schemas() []MetricSchema {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	schemas := make([]MetricSchema, 0, len(r.metrics))
+	for _, schema := range r.metrics {
+		schemas = append(schemas, schema)
+	}
+	sort.Slice(schemas, func(i, j int) bool {
+		return schemas[i].Name < schemas[j].Name
+	})
+	return schemas
+}
+
+// Validate checks a metric type, name, and tags against the registry and
+// returns a descriptive error if they do not match a declared metric.
+func (r *Registry) Validate(metricType, name string, tags map[string]string) error {
+	schema, ok := r.Lookup(name)
+	if !ok {
+		if similar := r.similar(name); similar != "" {
+			return fmt.Errorf("metric '%s' is not registered, did you mean '%s'?", name, similar)
+		}
+		return fmt.Errorf("metric '%s' is not registered", name)
+	}
+
+	if schema.Type != metricType {
+		return fmt.Errorf("metric '%s' is registered as a %s but was emitted as a %s", name, schema.Type, metricType)
+	}
+
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		allowed, ok := schema.Tags[k]
+		if !ok {
+			return fmt.Errorf("metric '%s' does not allow tag '%s', allowed tags are %s", name, k, formatTagKeys(schema.Tags))
+		}
+		if len(allowed) == 0 {
+			continue
+		}
+		found := false
+		for _, v := range allowed {
+			if tags[k] == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("metric '%s' tag '%s' does not allow value '%s', allowed values are %v", name, k, tags[k], allowed)
+		}
+	}
+
+	return nil
+}
+
+// similar returns the closest registered name to a typo, if any is close.
+func (r *Registry) similar(name string) string {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	best := ""
+	bestDistance := len(name)/3 + 1
+	for registered := range r.metrics {
+		d := levenshtein.Distance(name, registered)
+		if d < bestDistance || (d == bestDistance && best != "" && registered < best) {
+			best = registered
+			bestDistance = d
+		}
+	}
+	return best
+}
+
+// formatTagKeys returns the sorted allowed tag keys for error messages.
+func formatTagKeys(tags map[string][]string) string {
+	if len(tags) == 0 {
+		return "none"
+	}
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return "[" + strings.Join(keys, " ") + "]"
+}
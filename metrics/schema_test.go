@@ -0,0 +1,195 @@
+package metrics_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/istreamlabs/go-metrics/metrics"
+)
+
+func testRegistry(t *testing.T) *metrics.Registry {
+	registry, err := metrics.NewRegistry(
+		metrics.MetricSchema{
+			Name:        "requests.count",
+			Type:        "Count",
+			Unit:        "request",
+			Description: "Number of handled requests.",
+			Tags: map[string][]string{
+				"method": {"GET", "POST"},
+				"status": nil,
+			},
+		},
+		metrics.MetricSchema{Name: "queue.depth", Type: "Gauge"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return registry
+}
+
+func TestRegistryValidate(t *testing.T) {
+	registry := testRegistry(t)
+
+	for _, tt := range []struct {
+		metricType string
+		name       string
+		tags       map[string]string
+		expected   string
+	}{
+		{"Count", "requests.count", map[string]string{"method": "GET", "status": "200"}, ""},
+		{"Gauge", "queue.depth", nil, ""},
+		{"Count", "request.count", nil, "metric 'request.count' is not registered, did you mean 'requests.count'?"},
+		{"Count", "other", nil, "metric 'other' is not registered"},
+		{"Gauge", "requests.count", nil, "metric 'requests.count' is registered as a Count but was emitted as a Gauge"},
+		{"Count", "requests.count", map[string]string{"user": "1"}, "metric 'requests.count' does not allow tag 'user', allowed tags are [method status]"},
+		{"Count", "requests.count", map[string]string{"method": "PUT"}, "metric 'requests.count' tag 'method' does not allow value 'PUT', allowed values are [GET POST]"},
+		{"Gauge", "queue.depth", map[string]string{"name": "jobs"}, "metric 'queue.depth' does not allow tag 'name', allowed tags are none"},
+	} {
+		err := registry.Validate(tt.metricType, tt.name, tt.tags)
+		if tt.expected == "" && err != nil {
+			t.Errorf("Expected %s %s to be valid but got %v", tt.metricType, tt.name, err)
+		}
+		if tt.expected != "" && (err == nil || err.Error() != tt.expected) {
+			t.Errorf("Expected error %q but got %v", tt.expected, err)
+		}
+	}
+}
+
+func TestRegistryRegisterErrors(t *testing.T) {
+	if _, err := metrics.NewRegistry(metrics.MetricSchema{Type: "Count"}); err == nil {
+		t.Fatal("Expected missing name error")
+	}
+	if _, err := metrics.NewRegistry(metrics.MetricSchema{Name: "a", Type: "Counter"}); err == nil || !strings.Contains(err.Error(), "unknown type 'Counter'") {
+		t.Fatalf("Expected unknown type error but got %v", err)
+	}
+
+	registry := testRegistry(t)
+	if err := registry.Register(metrics.MetricSchema{Name: "queue.depth", Type: "Gauge"}); err == nil {
+		t.Fatal("Expected duplicate name error")
+	}
+}
+
+func TestLoadRegistry(t *testing.T) {
+	registry, err := metrics.LoadRegistry(strings.NewReader(`{
+		"metrics": [
+			{"name": "requests.count", "type": "Count", "unit": "request", "tags": {"method": ["GET"], "status": []}},
+			{"name": "latency", "type": "Timing"}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schemas := registry.Schemas()
+	if len(schemas) != 2 || schemas[0].Name != "latency" || schemas[1].Unit != "request" {
+		t.Fatalf("Unexpected schemas %+v", schemas)
+	}
+	if err := registry.Validate("Count", "requests.count", map[string]string{"status": "500"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := metrics.LoadRegistryFile("testdata/missing.json"); err == nil {
+		t.Fatal("Expected error loading missing file")
+	}
+}
+
+func TestLoadRegistryYAML(t *testing.T) {
+	registry, err := metrics.LoadRegistryFile("testdata/registry.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schemas := registry.Schemas()
+	if len(schemas) != 2 || schemas[1].Description != "Number of handled requests." {
+		t.Fatalf("Unexpected schemas %+v", schemas)
+	}
+	if err := registry.Validate("Count", "requests.count", map[string]string{"method": "GET", "status": "500"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.Validate("Count", "requests.count", map[string]string{"method": "PUT"}); err == nil {
+		t.Fatal("Expected disallowed tag value error")
+	}
+
+	if _, err := metrics.LoadRegistryYAML(strings.NewReader("metrics:\n  - name: a\n    type: Counter\n")); err == nil {
+		t.Fatal("Expected unknown type error")
+	}
+}
+
+func TestMetricTypes(t *testing.T) {
+	types := metrics.MetricTypes()
+	types[0] = "Counter"
+
+	if metrics.MetricTypes()[0] != "Count" {
+		t.Fatal("Expected a copy of the metric types")
+	}
+}
+
+func TestValidatingClientFail(t *testing.T) {
+	failer := &errorTest{}
+	recorder := metrics.NewRecorderClient().WithErrorTest(failer)
+	client := metrics.NewValidatingClient(recorder, testRegistry(t), metrics.ValidationFail)
+
+	client.WithTags(map[string]string{"method": "GET"}).Incr("requests.count")
+	client.Gauge("queue.depth", 5)
+	client.Count("queue.depth", 1)
+	client.WithTags(map[string]string{"method": "DELETE"}).Incr("requests.count")
+
+	if recorder.Length() != 2 {
+		t.Fatalf("Expected 2 valid calls but got %d", recorder.Length())
+	}
+	if len(failer.messages) != 2 {
+		t.Fatalf("Expected 2 failures but got %v", failer.messages)
+	}
+	if !strings.Contains(failer.messages[0], "registered as a Gauge but was emitted as a Count") {
+		t.Fatalf("Unexpected failure %q", failer.messages[0])
+	}
+	if !strings.Contains(failer.messages[1], "does not allow value 'DELETE'") {
+		t.Fatalf("Unexpected failure %q", failer.messages[1])
+	}
+}
+
+func TestValidatingClientPanic(t *testing.T) {
+	client := metrics.NewValidatingClient(metrics.NewNullClient(), testRegistry(t), metrics.ValidationFail)
+
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(r.(string), "metric 'typo' is not registered") {
+			t.Fatalf("Expected panic but got %v", r)
+		}
+	}()
+	client.Incr("typo")
+}
+
+func TestValidatingClientDrop(t *testing.T) {
+	logs := &LogRecorder{}
+	recorder := metrics.NewRecorderClient().WithTest(t)
+	client := metrics.NewValidatingClient(recorder, testRegistry(t), metrics.ValidationDrop).WithLogger(logs)
+
+	client.WithRate(0.5).Gauge("queue.depth", 1)
+	client.Histogram("queue.depth", 1)
+	client.Timing("latency", 0)
+	client.Distribution("size", 1)
+	client.Decr("requests.count")
+
+	recorder.Expect("queue.depth").Rate(0.5)
+	recorder.Expect("requests.count").Value(-1)
+	if recorder.Length() != 2 {
+		t.Fatalf("Expected 2 calls but got %d", recorder.Length())
+	}
+	if len(logs.messages) != 3 || !strings.HasPrefix(logs.messages[0], "Dropping invalid metric: ") {
+		t.Fatalf("Unexpected logs %v", logs.messages)
+	}
+}
+
+func TestValidatingClientPassThrough(t *testing.T) {
+	logs := &LogRecorder{}
+	recorder := metrics.NewRecorderClient().WithTest(t)
+	client := metrics.NewValidatingClient(recorder, testRegistry(t), metrics.ValidationPassThrough).WithLogger(logs)
+
+	client.WithTags(map[string]string{"user": "1"}).Incr("requests.count")
+
+	recorder.Expect("requests.count").Tag("user", "1")
+	expected := "Invalid metric: metric 'requests.count' does not allow tag 'user', allowed tags are [method status]"
+	if len(logs.messages) != 1 || logs.messages[0] != expected {
+		t.Fatalf("Expected %q but got %v", expected, logs.messages)
+	}
+}
@@ -0,0 +1,310 @@
+package metrics
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// UpdateSnapshotsEnv is the environment variable which, when set to a
+// non-empty value, causes `RecorderClient.Snapshot` to rewrite golden files
+// instead of comparing against them.
+const UpdateSnapshotsEnv = "METRICS_UPDATE_SNAPSHOTS"
+
+// SnapshotOptions contains the configuration options for a snapshot.
+type SnapshotOptions struct {
+	Dir              string
+	Update           bool
+	NormalizeTimings bool
+	IgnoreValues     map[string]bool
+	IgnoreTags       map[string]bool
+	Sorted           bool
+}
+
+// SnapshotOption is a snapshot option.
+type SnapshotOption func(*SnapshotOptions)
+
+// SnapshotDir sets the directory containing golden files. The default is
+// `testdata`.
+func SnapshotDir(dir string) SnapshotOption {
+	return func(o *SnapshotOptions) {
+		o.Dir = dir
+	}
+}
+
+// UpdateSnapshot rewrites the golden file when `update` is true. This is
+// useful to wire up your own test flag.
+func UpdateSnapshot(update bool) SnapshotOption {
+	return func(o *SnapshotOptions) {
+		o.Update = o.Update || update
+	}
+}
+
+// NormalizeTimings replaces the value of every `Timing` call with `*`, since
+// durations are rarely stable between test runs.
+func NormalizeTimings() SnapshotOption {
+	return func(o *SnapshotOptions) {
+		o.NormalizeTimings = true
+	}
+}
+
+// IgnoreValues replaces the value of every metric with one of the given
+// names with `*`.
+func IgnoreValues(names ...string) SnapshotOption {
+	return func(o *SnapshotOptions) {
+		for _, name := range names {
+			o.IgnoreValues[name] = true
+		}
+	}
+}
+
+// IgnoreTags replaces the value of every tag with one of the given names
+// with `*`, which is useful for volatile tags like hostnames or request IDs.
+func IgnoreTags(names ...string) SnapshotOption {
+	return func(o *SnapshotOptions) {
+		for _, name := range names {
+			o.IgnoreTags[name] = true
+		}
+	}
+}
+
+// SortCalls sorts the serialized calls, which makes snapshots independent
+// of the order that metrics were emitted in, e.g. by concurrent code.
+func SortCalls() SnapshotOption {
+	return func(o *SnapshotOptions) {
+		o.Sorted = true
+	}
+}
+
+func resolveSnapshotOptions(options []SnapshotOption) *SnapshotOptions {
+	o := &SnapshotOptions{
+		Dir:          "testdata",
+		Update:       os.Getenv(UpdateSnapshotsEnv) != "",
+		IgnoreValues: map[string]bool{},
+		IgnoreTags:   map[string]bool{},
+	}
+
+	// Honor the conventional `-update` flag if the test package defines it.
+	if f := flag.Lookup("update"); f != nil && f.Value.String() == "true" {
+		o.Update = true
+	}
+
+	for _, option := range options {
+		option(o)
+	}
+	return o
+}
+
+// snapshotLine serializes a single call like `Call.String()`, applying any
+// normalization options.
+func snapshotLine(call Call, o *SnapshotOptions) string {
+	var tagMap map[string]string
+	switch t := call.(type) {
+	case *MetricCall:
+		tagMap = t.TagMap
+	case *EventCall:
+		tagMap = t.TagMap
+	default:
+		return call.String()
+	}
+
+	tags := make([]string, 0, len(tagMap))
+	for k, v := range tagMap {
+		if o.IgnoreTags[k] {
+			v = "*"
+		}
+		tags = append(tags, buildTag(k, v))
+	}
+	sort.Strings(tags)
+
+	if e, ok := call.(*EventCall); ok {
+		return fmt.Sprintf("%s:%s%v", e.Event.Title, e.Event.Text, tags)
+	}
+
+	m := call.(*MetricCall)
+	value := fmt.Sprintf("%v", m.Value)
+	if o.IgnoreValues[m.Name] || (o.NormalizeTimings && m.Type == "Timing") {
+		value = "*"
+	}
+	if m.Rate != 1.0 {
+		return fmt.Sprintf("%s:%s(%v)%v", m.Name, value, m.Rate, tags)
+	}
+	return fmt.Sprintf("%s:%s%v", m.Name, value, tags)
+}
+
+// Snapshot compares the recorded calls against the golden file
+// `testdata/NAME.golden`, failing the test with a unified diff if they do not
+// match. Each call is serialized on its own line using the same format as
+// `Call.String()`.
+//
+// Golden files are written instead of compared when the `METRICS_UPDATE_SNAPSHOTS`
+// environment variable is set, when the test package defines an `-update`
+// flag which is set, or when the `UpdateSnapshot(true)` option is passed:
+//
+//   func TestHandler(t *testing.T) {
+//     recorder := metrics.NewRecorderClient().WithTest(t)
+//     handler(recorder)
+//
+//     recorder.Snapshot("handler", metrics.NormalizeTimings(), metrics.IgnoreTags("host"))
+//   }
+func (c *RecorderClient) Snapshot(name string, options ...SnapshotOption) {
+	c.helper().Helper()
+	o := resolveSnapshotOptions(options)
+
+	calls := c.callsCopy()
+	lines := make([]string, 0, len(calls))
+	for _, call := range calls {
+		lines = append(lines, snapshotLine(call, o))
+	}
+	if o.Sorted {
+		sort.Strings(lines)
+	}
+	actual := strings.Join(lines, "\n")
+	if len(lines) > 0 {
+		actual += "\n"
+	}
+
+	fname := filepath.Join(o.Dir, name+".golden")
+	if o.Update {
+		if err := os.MkdirAll(o.Dir, 0755); err != nil {
+			c.Fatalf("Unable to create snapshot directory: %v.", err)
+			return
+		}
+		if err := ioutil.WriteFile(fname, []byte(actual), 0644); err != nil {
+			c.Fatalf("Unable to write snapshot: %v.", err)
+		}
+		return
+	}
+
+	expected, err := ioutil.ReadFile(fname)
+	if err != nil {
+		c.Fatalf("Unable to read snapshot, set %s=1 to create it: %v.", UpdateSnapshotsEnv, err)
+		return
+	}
+
+	if string(expected) != actual {
+		c.Fatalf("Snapshot '%s' does not match:\n%s", fname, unifiedDiff(fname, "actual", string(expected), actual))
+	}
+}
+
+// diffContext is the number of unchanged lines shown around each change.
+const diffContext = 3
+
+// unifiedDiff returns a line-based unified diff between two strings.
+func unifiedDiff(fromName, toName, from, to string) string {
+	a := splitLines(from)
+	b := splitLines(to)
+
+	// Compute the longest common subsequence lengths from the end of each
+	// input so that the edit script can be walked forwards.
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	type edit struct {
+		op   byte
+		line string
+		// Line numbers (zero-based) in `a` and `b` before this edit.
+		ai, bi int
+	}
+	var edits []edit
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			edits = append(edits, edit{' ', a[i], i, j})
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] > lcs[i+1][j]):
+			edits = append(edits, edit{'+', b[j], i, j})
+			j++
+		default:
+			edits = append(edits, edit{'-', a[i], i, j})
+			i++
+		}
+	}
+
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "--- %s\n+++ %s\n", fromName, toName)
+	for start := 0; start < len(edits); {
+		// Find the next change.
+		for start < len(edits) && edits[start].op == ' ' {
+			start++
+		}
+		if start == len(edits) {
+			break
+		}
+
+		// Extend the hunk until there is a long enough run of unchanged lines.
+		end := start
+		for k := start; k < len(edits); k++ {
+			if edits[k].op != ' ' {
+				end = k + 1
+			} else if k-end >= 2*diffContext {
+				break
+			}
+		}
+
+		lo := start - diffContext
+		if lo < 0 {
+			lo = 0
+		}
+		hi := end + diffContext
+		if hi > len(edits) {
+			hi = len(edits)
+		}
+
+		aCount, bCount := 0, 0
+		for _, e := range edits[lo:hi] {
+			if e.op != '+' {
+				aCount++
+			}
+			if e.op != '-' {
+				bCount++
+			}
+		}
+		// Empty ranges start at the line before them, so e.g. adding lines to
+		// an empty file is `@@ -0,0 +1,n @@`.
+		aStart, bStart := edits[lo].ai+1, edits[lo].bi+1
+		if aCount == 0 {
+			aStart--
+		}
+		if bCount == 0 {
+			bStart--
+		}
+		fmt.Fprintf(&buf, "@@ -%d,%d +%d,%d @@\n", aStart, aCount, bStart, bCount)
+		for _, e := range edits[lo:hi] {
+			buf.WriteByte(e.op)
+			buf.WriteString(e.line)
+			buf.WriteByte('\n')
+		}
+
+		start = hi
+	}
+
+	return buf.String()
+}
+
+// splitLines splits a string into lines, ignoring a trailing newline.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -0,0 +1,124 @@
+package metrics_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-go/v5/statsd"
+	"github.com/istreamlabs/go-metrics/metrics"
+)
+
+// emitSnapshotMetrics emits a fixed set of metrics with some volatile values.
+func emitSnapshotMetrics(client metrics.Client, host string, latency time.Duration) {
+	tagged := client.WithTags(map[string]string{
+		"host":   host,
+		"status": "200",
+	})
+	tagged.Incr("requests.count")
+	tagged.Timing("requests.latency", latency)
+	client.WithRate(0.5).Gauge("queue.length", 3)
+	client.Event(statsd.NewEvent("deploy", "v1.2.3"))
+}
+
+func TestRecorderSnapshot(t *testing.T) {
+	recorder := metrics.NewRecorderClient().WithTest(t)
+	emitSnapshotMetrics(recorder, "host-1234", 123*time.Millisecond)
+
+	recorder.Snapshot("recorder", metrics.NormalizeTimings(), metrics.IgnoreTags("host"))
+}
+
+func TestRecorderSnapshotMismatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	recorder := metrics.NewRecorderClient().WithTest(t)
+	recorder.Incr("one")
+	recorder.Incr("two")
+	recorder.Incr("three")
+	recorder.Snapshot("mismatch", metrics.SnapshotDir(dir), metrics.UpdateSnapshot(true))
+
+	golden, err := ioutil.ReadFile(filepath.Join(dir, "mismatch.golden"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ExpectEqual(t, "one:1[]\ntwo:1[]\nthree:1[]\n", string(golden))
+
+	failer := &messageTest{}
+	recorder = metrics.NewRecorderClient().WithTest(failer)
+	recorder.Incr("one")
+	recorder.Count("two", 2)
+	recorder.Incr("three")
+	recorder.Snapshot("mismatch", metrics.SnapshotDir(dir))
+
+	expected := "@@ -1,3 +1,3 @@\n one:1[]\n-two:1[]\n+two:2[]\n three:1[]\n"
+	if !strings.Contains(failer.message, expected) {
+		t.Fatalf("Expected failure message to contain %q but got %q", expected, failer.message)
+	}
+}
+
+func TestRecorderSnapshotEmpty(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "empty.golden"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	failer := &messageTest{}
+	recorder := metrics.NewRecorderClient().WithTest(failer)
+	recorder.Incr("one")
+	recorder.Incr("two")
+	recorder.Snapshot("empty", metrics.SnapshotDir(dir))
+
+	expected := "@@ -0,0 +1,2 @@\n+one:1[]\n+two:1[]\n"
+	if !strings.Contains(failer.message, expected) {
+		t.Fatalf("Expected failure message to contain %q but got %q", expected, failer.message)
+	}
+
+	// Removing every line is the reverse.
+	if err := ioutil.WriteFile(filepath.Join(dir, "empty.golden"), []byte("one:1[]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	failer = &messageTest{}
+	metrics.NewRecorderClient().WithTest(failer).Snapshot("empty", metrics.SnapshotDir(dir))
+
+	expected = "@@ -1,1 +0,0 @@\n-one:1[]\n"
+	if !strings.Contains(failer.message, expected) {
+		t.Fatalf("Expected failure message to contain %q but got %q", expected, failer.message)
+	}
+}
+
+func TestRecorderSnapshotSorted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	recorder := metrics.NewRecorderClient().WithTest(t)
+	recorder.Count("b", 1)
+	recorder.Count("a", 5)
+	recorder.Snapshot("sorted", metrics.SnapshotDir(dir), metrics.SortCalls(), metrics.IgnoreValues("a"), metrics.UpdateSnapshot(true))
+
+	golden, err := ioutil.ReadFile(filepath.Join(dir, "sorted.golden"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ExpectEqual(t, "a:*[]\nb:1[]\n", string(golden))
+
+	ExpectFailure(t, "Expecting a missing snapshot to fail",
+		func(r *metrics.RecorderClient) {
+			r.Incr("a")
+			r.Snapshot("missing", metrics.SnapshotDir(dir))
+		})
+}
@@ -0,0 +1,108 @@
+package metrics
+
+import "sync"
+
+// subscriber receives calls as they are recorded.
+type subscriber struct {
+	filter *expectation
+	f      func(Call)
+}
+
+// deliver passes the call to the subscriber if it matches the filter.
+func (s *subscriber) deliver(call Call) {
+	if s.filter == nil || s.filter.matches(call) {
+		s.f(call)
+	}
+}
+
+// Filter creates a query which is not evaluated immediately, for use with
+// `Subscribe` and `SubscribeChan`. It finds metrics (by name) or events (by
+// title) and supports the same chainable filters as `Expect`:
+//
+//   recorder.Filter("http.requests").Tag("status", "500")
+func (c *RecorderClient) Filter(id string) Query {
+	return &expectation{recorder: c, id: id, hasID: true}
+}
+
+// Subscribe calls `f` with each new call recorded by this recorder or any of
+// its clones. If `filter` is not `nil`, only calls matching it are passed
+// along. The filter must be created via `Filter` or `Match`. Returns a function which
+// unsubscribes `f`.
+//
+// Callbacks are run on the emitting goroutine after the recorder lock is
+// released, so they may query the recorder or emit metrics. With concurrent
+// emitters, callbacks may run concurrently and out of order.
+//
+//   count := 0
+//   unsubscribe := recorder.Subscribe(func(call metrics.Call) {
+//     count++
+//   }, recorder.Filter("http.requests"))
+//   defer unsubscribe()
+func (c *RecorderClient) Subscribe(f func(Call), filter Query) (unsubscribe func()) {
+	s := &subscriber{f: f}
+	if filter != nil {
+		s.filter = toExpectation(filter)
+	}
+
+	c.callInfo.RWMutex.Lock()
+	defer c.callInfo.RWMutex.Unlock()
+	if c.callInfo.Subscribers == nil {
+		c.callInfo.Subscribers = make(map[int]*subscriber)
+	}
+	id := c.callInfo.NextSubscriberID
+	c.callInfo.NextSubscriberID++
+	c.callInfo.Subscribers[id] = s
+
+	return func() {
+		c.callInfo.RWMutex.Lock()
+		defer c.callInfo.RWMutex.Unlock()
+		delete(c.callInfo.Subscribers, id)
+	}
+}
+
+// SubscribeChan sends each new call matching `filter` to `ch`. It works like
+// `Subscribe`, and the emitting goroutine blocks until the call is sent, so
+// `ch` should be buffered or actively read from. Unsubscribing abandons any
+// blocked sends and waits for in-flight sends to finish, after which nothing
+// more is sent, so it is then safe to close the channel or stop reading.
+//
+//   calls := make(chan metrics.Call, 10)
+//   unsubscribe := recorder.SubscribeChan(calls, recorder.Filter("queue.length"))
+//   defer unsubscribe()
+//
+//   select {
+//   case call := <-calls:
+//     // ...
+//   case <-time.After(time.Second):
+//     t.Fatal("Timed out waiting for metric")
+//   }
+func (c *RecorderClient) SubscribeChan(ch chan<- Call, filter Query) (unsubscribe func()) {
+	done := make(chan struct{})
+	var lock sync.RWMutex
+	closed := false
+
+	remove := c.Subscribe(func(call Call) {
+		lock.RLock()
+		defer lock.RUnlock()
+		if closed {
+			return
+		}
+		select {
+		case ch <- call:
+		case <-done:
+		}
+	}, filter)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			remove()
+			close(done)
+
+			// Wait for in-flight sends, which `done` unblocks.
+			lock.Lock()
+			closed = true
+			lock.Unlock()
+		})
+	}
+}
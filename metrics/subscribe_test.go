@@ -0,0 +1,110 @@
+package metrics_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/istreamlabs/go-metrics/metrics"
+)
+
+func TestRecorderSubscribe(t *testing.T) {
+	recorder := metrics.NewRecorderClient().WithTest(t)
+
+	var all, errors []string
+	unsubscribeAll := recorder.Subscribe(func(call metrics.Call) {
+		all = append(all, call.String())
+	}, nil)
+	unsubscribeErrors := recorder.Subscribe(func(call metrics.Call) {
+		errors = append(errors, call.String())
+
+		// Callbacks may safely use the recorder.
+		recorder.Expect("requests")
+	}, recorder.Filter("requests").TagMatch("status", "^5"))
+
+	tagged := recorder.WithTags(map[string]string{"status": "200"})
+	tagged.Incr("requests")
+	recorder.WithTags(map[string]string{"status": "503"}).Incr("requests")
+	recorder.Incr("other")
+
+	ExpectEqual(t, []string{"requests:1[status:200]", "requests:1[status:503]", "other:1[]"}, all)
+	ExpectEqual(t, []string{"requests:1[status:503]"}, errors)
+
+	unsubscribeAll()
+	unsubscribeErrors()
+	recorder.WithTags(map[string]string{"status": "500"}).Incr("requests")
+	if len(all) != 3 || len(errors) != 1 {
+		t.Fatalf("Expected no calls after unsubscribing but got %v and %v", all, errors)
+	}
+}
+
+func TestRecorderSubscribeChan(t *testing.T) {
+	recorder := metrics.NewRecorderClient().WithTest(t)
+
+	calls := make(chan metrics.Call, 10)
+	unsubscribe := recorder.SubscribeChan(calls, recorder.Filter("queue.length").Value(0))
+	defer unsubscribe()
+
+	// Emit concurrently from several goroutines.
+	wg := sync.WaitGroup{}
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			recorder.Gauge("queue.length", float64(i))
+		}(i)
+	}
+
+	select {
+	case call := <-calls:
+		ExpectEqual(t, 0.0, call.(*metrics.MetricCall).Value)
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for metric")
+	}
+	wg.Wait()
+
+	if len(calls) != 0 {
+		t.Fatalf("Expected a single matching call")
+	}
+}
+
+func TestRecorderSubscribeChanUnsubscribe(t *testing.T) {
+	recorder := metrics.NewRecorderClient().WithTest(t)
+	calls := make(chan metrics.Call)
+	unsubscribe := recorder.SubscribeChan(calls, nil)
+
+	// The emitter blocks since nothing reads from the channel.
+	emitted := make(chan struct{})
+	go func() {
+		recorder.Incr("blocked")
+		close(emitted)
+	}()
+	for recorder.Length() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// Unsubscribing unblocks the emitter, after which the channel can be
+	// closed without later calls panicking.
+	unsubscribe()
+	select {
+	case <-emitted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the emitter to unblock")
+	}
+	close(calls)
+	recorder.Incr("after")
+	unsubscribe()
+}
+
+func TestRecorderSubscribeInvalidFilter(t *testing.T) {
+	recorder := metrics.NewRecorderClient().WithTest(t)
+	recorder.Incr("foo")
+
+	defer func() {
+		if err := recover(); err == nil {
+			t.Fatalf("Subscribing with a non-deferred query should panic")
+		}
+	}()
+
+	recorder.Subscribe(func(metrics.Call) {}, recorder.Expect("foo"))
+}
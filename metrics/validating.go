@@ -0,0 +1,180 @@
+package metrics
+
+import (
+	"log"
+	"os"
+	"time"
+
+	"github.com/DataDog/datadog-go/v5/statsd"
+)
+
+// ValidationMode controls how a `ValidatingClient` handles calls which do not
+// match the registry.
+type ValidationMode int
+
+const (
+	// ValidationFail fails the attached test when wrapping a `RecorderClient`
+	// linked via `WithTest` or `WithErrorTest`, and panics otherwise. Use it
+	// in development and tests.
+	ValidationFail ValidationMode = iota
+
+	// ValidationDrop logs invalid calls and does not emit them.
+	ValidationDrop
+
+	// ValidationPassThrough logs invalid calls and emits them anyway.
+	ValidationPassThrough
+)
+
+// ValidatingClient checks every metric against a `Registry` before passing it
+// on to another client. This catches typos in metric names, metrics emitted
+// with the wrong type (e.g. `Gauge` instead of `Count`), and undeclared tags,
+// each of which would otherwise silently create a new time series.
+//
+//   var client metrics.Client = metrics.NewDataDogClient("127.0.0.1:8125", "myprefix")
+//   client = metrics.NewValidatingClient(client, registry, metrics.ValidationDrop)
+//
+// In tests, wrap a recorder to fail the test on invalid metrics:
+//
+//   recorder := metrics.NewRecorderClient().WithTest(t)
+//   client := metrics.NewValidatingClient(recorder, registry, metrics.ValidationFail)
+//
+// Only tags added via the validating client's `WithTags` are validated, so
+// global tags like `env` can be added to the wrapped client beforehand.
+// Events are passed through without validation.
+type ValidatingClient struct {
+	client   Client
+	registry *Registry
+	mode     ValidationMode
+	logger   InfoLogger
+	tagMap   map[string]string
+}
+
+// NewValidatingClient creates a new client which validates metrics against
+// `registry` before passing them on to `client`. Invalid metrics are logged
+// to stderr in the drop and pass-through modes, see `WithLogger`.
+func NewValidatingClient(client Client, registry *Registry, mode ValidationMode) *ValidatingClient {
+	return &ValidatingClient{
+		client:   client,
+		registry: registry,
+		mode:     mode,
+		logger:   log.New(os.Stderr, "", log.LstdFlags),
+	}
+}
+
+// WithLogger clones this client with a logger for invalid metrics.
+func (c *ValidatingClient) WithLogger(logger InfoLogger) *ValidatingClient {
+	return &ValidatingClient{
+		client:   c.client,
+		registry: c.registry,
+		mode:     c.mode,
+		logger:   logger,
+		tagMap:   c.tagMap,
+	}
+}
+
+// WithTags clones this client with additional tags. Duplicate tags overwrite
+// the existing value.
+func (c *ValidatingClient) WithTags(tags map[string]string) Client {
+	return &ValidatingClient{
+		client:   c.client.WithTags(tags),
+		registry: c.registry,
+		mode:     c.mode,
+		logger:   c.logger,
+		tagMap:   combine(c.tagMap, tags),
+	}
+}
+
+// WithRate clones this client with a given sample rate.
+func (c *ValidatingClient) WithRate(rate float64) Client {
+	return &ValidatingClient{
+		client:   c.client.WithRate(rate),
+		registry: c.registry,
+		mode:     c.mode,
+		logger:   c.logger,
+		tagMap:   c.tagMap,
+	}
+}
+
+// valid checks a call against the registry and handles any error according
+// to the validation mode. Returns whether the call should be emitted.
+func (c *ValidatingClient) valid(metricType, name string) bool {
+	err := c.registry.Validate(metricType, name, c.tagMap)
+	if err == nil {
+		return true
+	}
+
+	switch c.mode {
+	case ValidationDrop:
+		c.logger.Printf("Dropping invalid metric: %v", err)
+		return false
+	case ValidationPassThrough:
+		c.logger.Printf("Invalid metric: %v", err)
+		return true
+	}
+
+	if recorder, ok := c.client.(*RecorderClient); ok && (recorder.test != nil || recorder.errorer != nil) {
+		recorder.helper().Helper()
+		recorder.Fatalf("Invalid metric: %v.", err)
+		return false
+	}
+	panic("Invalid metric: " + err.Error())
+}
+
+// Close closes the wrapped client.
+func (c *ValidatingClient) Close() error {
+	return c.client.Close()
+}
+
+// Count adds some value to a metric.
+func (c *ValidatingClient) Count(name string, value int64) {
+	if c.valid("Count", name) {
+		c.client.Count(name, value)
+	}
+}
+
+// Incr adds one to a metric.
+func (c *ValidatingClient) Incr(name string) {
+	if c.valid("Count", name) {
+		c.client.Incr(name)
+	}
+}
+
+// Decr subtracts one from a metric.
+func (c *ValidatingClient) Decr(name string) {
+	if c.valid("Count", name) {
+		c.client.Decr(name)
+	}
+}
+
+// Gauge sets a numerical value.
+func (c *ValidatingClient) Gauge(name string, value float64) {
+	if c.valid("Gauge", name) {
+		c.client.Gauge(name, value)
+	}
+}
+
+// Event passes an event through without validation.
+func (c *ValidatingClient) Event(e *statsd.Event) {
+	c.client.Event(e)
+}
+
+// Timing logs timing information (in milliseconds).
+func (c *ValidatingClient) Timing(name string, value time.Duration) {
+	if c.valid("Timing", name) {
+		c.client.Timing(name, value)
+	}
+}
+
+// Histogram sets a numerical value.
+func (c *ValidatingClient) Histogram(name string, value float64) {
+	if c.valid("Histogram", name) {
+		c.client.Histogram(name, value)
+	}
+}
+
+// Distribution tracks the statistical distribution of a set of values.
+func (c *ValidatingClient) Distribution(name string, value float64) {
+	if c.valid("Distribution", name) {
+		c.client.Distribution(name, value)
+	}
+}
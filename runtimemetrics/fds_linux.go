@@ -0,0 +1,22 @@
+//go:build linux
+// +build linux
+
+package runtimemetrics
+
+import "os"
+
+// openFDs returns the number of open file descriptors of this process.
+func openFDs() (int, bool) {
+	f, err := os.Open("/proc/self/fd")
+	if err != nil {
+		return 0, false
+	}
+	defer f.Close()
+
+	names, err := f.Readdirnames(-1)
+	if err != nil {
+		return 0, false
+	}
+	// Exclude the descriptor used to read the directory itself.
+	return len(names) - 1, true
+}
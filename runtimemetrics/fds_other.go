@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package runtimemetrics
+
+// openFDs is only supported on Linux.
+func openFDs() (int, bool) {
+	return 0, false
+}
@@ -0,0 +1,199 @@
+// Package runtimemetrics periodically reports Go runtime and process metrics
+// via a `metrics.Client`:
+//
+//   collector := runtimemetrics.Start(client, runtimemetrics.WithInterval(10*time.Second))
+//   defer collector.Stop()
+//
+// The following metrics are emitted with a `runtime` prefix by default:
+//
+//   - `runtime.goroutines` gauge
+//   - `runtime.cgo.calls` gauge of the total number of cgo calls
+//   - `runtime.mem.*` gauges from `runtime.MemStats`, e.g. `runtime.mem.heap.alloc`
+//   - `runtime.gc.count`, `runtime.gc.pause_total` (in seconds),
+//     `runtime.gc.cpu_fraction`, and `runtime.gc.next` gauges
+//   - `runtime.gc.pause` distribution of each GC pause in seconds, starting
+//     from when the collector was created
+//   - `runtime.fds.open` gauge of open file descriptors on Linux
+package runtimemetrics
+
+import (
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/istreamlabs/go-metrics/metrics"
+)
+
+// DefaultInterval is the interval between collections when none is set, or
+// when the set interval is zero or negative.
+const DefaultInterval = 10 * time.Second
+
+// Options contains the configuration options for a collector.
+type Options struct {
+	// Prefix for metric names, defaulting to `runtime`.
+	Prefix string
+
+	// Interval between collections, defaulting to `DefaultInterval`.
+	Interval time.Duration
+}
+
+// Option is a collector option.
+type Option func(*Options)
+
+// WithPrefix sets the metric name prefix.
+func WithPrefix(prefix string) Option {
+	return func(o *Options) {
+		o.Prefix = prefix
+	}
+}
+
+// WithInterval sets the interval between collections. Zero or negative
+// intervals use `DefaultInterval`.
+func WithInterval(interval time.Duration) Option {
+	return func(o *Options) {
+		o.Interval = interval
+	}
+}
+
+// Collector samples runtime metrics.
+type Collector struct {
+	client  metrics.Client
+	options *Options
+
+	lock   sync.Mutex
+	numGC  uint32
+	done   chan struct{}
+	wg     sync.WaitGroup
+	closed bool
+}
+
+// NewCollector creates a collector without starting it. Call `Collect` to
+// emit metrics once, e.g. from tests or your own scheduler.
+func NewCollector(client metrics.Client, options ...Option) *Collector {
+	o := &Options{
+		Prefix:   "runtime",
+		Interval: DefaultInterval,
+	}
+	for _, option := range options {
+		option(o)
+	}
+	if o.Interval <= 0 {
+		o.Interval = DefaultInterval
+	}
+
+	// Only pauses after the collector is created are emitted.
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+
+	return &Collector{
+		client:  client,
+		options: o,
+		done:    make(chan struct{}),
+		numGC:   stats.NumGC,
+	}
+}
+
+// Start creates a collector and starts collecting metrics immediately and
+// then on each interval until `Stop` is called.
+func Start(client metrics.Client, options ...Option) *Collector {
+	c := NewCollector(client, options...)
+	c.wg.Add(1)
+	go c.run()
+	return c
+}
+
+func (c *Collector) run() {
+	defer c.wg.Done()
+	ticker := time.NewTicker(c.options.Interval)
+	defer ticker.Stop()
+
+	for {
+		c.Collect()
+		select {
+		case <-ticker.C:
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// Stop stops collecting and waits for any in-progress collection to finish.
+// It is safe to call more than once.
+func (c *Collector) Stop() {
+	c.lock.Lock()
+	if !c.closed {
+		c.closed = true
+		close(c.done)
+	}
+	c.lock.Unlock()
+	c.wg.Wait()
+}
+
+// Collect samples and emits all metrics once.
+func (c *Collector) Collect() {
+	prefix := c.options.Prefix + "."
+
+	c.client.Gauge(prefix+"goroutines", float64(runtime.NumGoroutine()))
+	c.client.Gauge(prefix+"cgo.calls", float64(runtime.NumCgoCall()))
+
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+
+	gauges := []struct {
+		name  string
+		value uint64
+	}{
+		{"mem.sys", stats.Sys},
+		{"mem.total_alloc", stats.TotalAlloc},
+		{"mem.mallocs", stats.Mallocs},
+		{"mem.frees", stats.Frees},
+		{"mem.heap.alloc", stats.HeapAlloc},
+		{"mem.heap.sys", stats.HeapSys},
+		{"mem.heap.idle", stats.HeapIdle},
+		{"mem.heap.inuse", stats.HeapInuse},
+		{"mem.heap.released", stats.HeapReleased},
+		{"mem.heap.objects", stats.HeapObjects},
+		{"mem.stack.inuse", stats.StackInuse},
+		{"mem.stack.sys", stats.StackSys},
+		{"gc.count", uint64(stats.NumGC)},
+		{"gc.next", stats.NextGC},
+	}
+	for _, g := range gauges {
+		c.client.Gauge(prefix+g.name, float64(g.value))
+	}
+	c.client.Gauge(prefix+"gc.pause_total", time.Duration(stats.PauseTotalNs).Seconds())
+	c.client.Gauge(prefix+"gc.cpu_fraction", stats.GCCPUFraction)
+
+	// Concurrent collections may read stats out of order, so only the
+	// collection which sees the newest GC count emits the pauses since the
+	// previous one.
+	var pauses []time.Duration
+	c.lock.Lock()
+	if stats.NumGC > c.numGC {
+		pauses = newPauses(&stats, c.numGC)
+		c.numGC = stats.NumGC
+	}
+	c.lock.Unlock()
+	for _, pause := range pauses {
+		c.client.Distribution(prefix+"gc.pause", pause.Seconds())
+	}
+
+	if fds, ok := openFDs(); ok {
+		c.client.Gauge(prefix+"fds.open", float64(fds))
+	}
+}
+
+// newPauses returns the GC pauses since `lastNumGC`. The runtime only keeps
+// the most recent 256 pauses.
+func newPauses(stats *runtime.MemStats, lastNumGC uint32) []time.Duration {
+	count := stats.NumGC - lastNumGC
+	if count > uint32(len(stats.PauseNs)) {
+		count = uint32(len(stats.PauseNs))
+	}
+
+	pauses := make([]time.Duration, 0, count)
+	for i := stats.NumGC - count; i < stats.NumGC; i++ {
+		pauses = append(pauses, time.Duration(stats.PauseNs[i%uint32(len(stats.PauseNs))]))
+	}
+	return pauses
+}
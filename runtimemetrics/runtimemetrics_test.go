@@ -0,0 +1,119 @@
+package runtimemetrics_test
+
+import (
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/istreamlabs/go-metrics/metrics"
+	"github.com/istreamlabs/go-metrics/runtimemetrics"
+)
+
+func TestCollect(t *testing.T) {
+	recorder := metrics.NewRecorderClient().WithTest(t)
+	collector := runtimemetrics.NewCollector(recorder, runtimemetrics.WithPrefix("app.runtime"))
+
+	runtime.GC()
+	collector.Collect()
+
+	recorder.Expect("app.runtime.goroutines").Where("at least one goroutine", func(c metrics.Call) bool {
+		return c.(*metrics.MetricCall).Value >= 1
+	})
+	for _, name := range []string{
+		"cgo.calls", "mem.sys", "mem.heap.alloc", "mem.heap.objects", "mem.stack.inuse",
+		"gc.count", "gc.next", "gc.pause_total", "gc.cpu_fraction",
+	} {
+		recorder.Expect("app.runtime." + name)
+	}
+	recorder.Expect("app.runtime.gc.pause").MinTimes(1)
+	if runtime.GOOS == "linux" {
+		recorder.Expect("app.runtime.fds.open")
+	}
+
+	// Only new GC pauses are emitted on subsequent collections.
+	recorder.Reset()
+	collector.Collect()
+	runtime.GC()
+	collector.Collect()
+	if calls := recorder.If("app.runtime.gc.pause").GetCalls(); len(calls) < 1 || len(calls) > 2 {
+		t.Fatalf("Expected only new GC pauses but got %d", len(calls))
+	}
+}
+
+func TestCollectPausesOnce(t *testing.T) {
+	// Disable automatic GC so that only explicit collections cause pauses.
+	defer debug.SetGCPercent(debug.SetGCPercent(-1))
+
+	// Pauses from before the collector was created are not emitted.
+	runtime.GC()
+	runtime.GC()
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	recorder := metrics.NewRecorderClient().WithTest(t)
+	collector := runtimemetrics.NewCollector(recorder)
+	collector.Collect()
+	recorder.If("runtime.gc.pause").Reject()
+
+	// Concurrent collections emit each new pause exactly once. Concurrent
+	// `runtime.GC` calls may share a cycle, so count the actual cycles.
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runtime.GC()
+			collector.Collect()
+		}()
+	}
+	wg.Wait()
+	collector.Collect()
+	runtime.ReadMemStats(&after)
+
+	expected := int(after.NumGC - before.NumGC)
+	if calls := recorder.If("runtime.gc.pause").GetCalls(); expected == 0 || len(calls) != expected {
+		t.Fatalf("Expected %d GC pauses but got %d", expected, len(calls))
+	}
+}
+
+func TestStartStop(t *testing.T) {
+	recorder := metrics.NewRecorderClient().WithTest(t)
+
+	calls := make(chan metrics.Call, 100)
+	unsubscribe := recorder.SubscribeChan(calls, metrics.Match().Contains("runtime.goroutines"))
+	defer unsubscribe()
+
+	collector := runtimemetrics.Start(recorder, runtimemetrics.WithInterval(time.Millisecond))
+	for i := 0; i < 2; i++ {
+		select {
+		case <-calls:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for collection")
+		}
+	}
+	collector.Stop()
+	collector.Stop()
+
+	// Stop waits for the collection goroutine to return, so a subscription
+	// made afterwards never receives anything.
+	after := make(chan metrics.Call, 1)
+	unsubscribeAfter := recorder.SubscribeChan(after, nil)
+	defer unsubscribeAfter()
+
+	select {
+	case call := <-after:
+		t.Fatalf("Expected no metrics after stopping but got %v", call)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func TestStartDefaultInterval(t *testing.T) {
+	recorder := metrics.NewRecorderClient().WithTest(t)
+
+	// A zero interval falls back to the default rather than panicking.
+	collector := runtimemetrics.Start(recorder, runtimemetrics.WithInterval(0))
+	collector.Stop()
+
+	recorder.Expect("runtime.goroutines")
+}
@@ -0,0 +1,214 @@
+package sqlmetrics
+
+import (
+	"context"
+	"database/sql/driver"
+	"time"
+)
+
+// conn wraps a driver connection. It implements the optional context
+// interfaces and falls back to the non-context methods, or `driver.ErrSkip`,
+// when the wrapped connection does not.
+type conn struct {
+	conn     driver.Conn
+	recorder *recorder
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	start := time.Now()
+	var s driver.Stmt
+	var err error
+	if cp, ok := c.conn.(driver.ConnPrepareContext); ok {
+		s, err = cp.PrepareContext(ctx, query)
+	} else {
+		s, err = c.conn.Prepare(query)
+	}
+	c.recorder.record(ctx, "prepare", start, err)
+	if err != nil {
+		return nil, err
+	}
+	return &stmt{stmt: s, recorder: c.recorder, ctx: ctx}, nil
+}
+
+func (c *conn) Close() error {
+	return c.conn.Close()
+}
+
+// IsValid implements `driver.Validator` so that `database/sql` still discards
+// connections which the wrapped driver reports as invalid.
+func (c *conn) IsValid() bool {
+	if v, ok := c.conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	start := time.Now()
+	var t driver.Tx
+	var err error
+	if cb, ok := c.conn.(driver.ConnBeginTx); ok {
+		t, err = cb.BeginTx(ctx, opts)
+	} else {
+		t, err = c.conn.Begin()
+	}
+	c.recorder.record(ctx, "begin", start, err)
+	if err != nil {
+		return nil, err
+	}
+	return &tx{tx: t, recorder: c.recorder, ctx: ctx, start: start}, nil
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	result, err := execer.ExecContext(ctx, query, args)
+	c.recorder.record(ctx, "exec", start, err)
+	return result, err
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	rows, err := queryer.QueryContext(ctx, query, args)
+	c.recorder.record(ctx, "query", start, err)
+	return rows, err
+}
+
+func (c *conn) Ping(ctx context.Context) error {
+	if p, ok := c.conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *conn) ResetSession(ctx context.Context) error {
+	if r, ok := c.conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *conn) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := c.conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// stmt wraps a prepared statement. Its metrics use the query name from the
+// context it was prepared with unless it is executed with a named context.
+type stmt struct {
+	stmt     driver.Stmt
+	recorder *recorder
+	ctx      context.Context
+}
+
+// context returns the execution context if it has a query name, otherwise
+// the prepare context.
+func (s *stmt) context(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(queryNameKey{}).(string); ok {
+		return ctx
+	}
+	return s.ctx
+}
+
+func (s *stmt) Close() error {
+	return s.stmt.Close()
+}
+
+func (s *stmt) NumInput() int {
+	return s.stmt.NumInput()
+}
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	start := time.Now()
+	result, err := s.stmt.Exec(args)
+	s.recorder.record(s.ctx, "exec", start, err)
+	return result, err
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	start := time.Now()
+	rows, err := s.stmt.Query(args)
+	s.recorder.record(s.ctx, "query", start, err)
+	return rows, err
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+	var result driver.Result
+	var err error
+	if se, ok := s.stmt.(driver.StmtExecContext); ok {
+		result, err = se.ExecContext(ctx, args)
+	} else {
+		var values []driver.Value
+		if values, err = namedToValues(args); err == nil {
+			result, err = s.stmt.Exec(values)
+		}
+	}
+	s.recorder.record(s.context(ctx), "exec", start, err)
+	return result, err
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	var rows driver.Rows
+	var err error
+	if sq, ok := s.stmt.(driver.StmtQueryContext); ok {
+		rows, err = sq.QueryContext(ctx, args)
+	} else {
+		var values []driver.Value
+		if values, err = namedToValues(args); err == nil {
+			rows, err = s.stmt.Query(values)
+		}
+	}
+	s.recorder.record(s.context(ctx), "query", start, err)
+	return rows, err
+}
+
+func (s *stmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := s.stmt.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// tx wraps a transaction and records its total duration on commit or
+// rollback.
+type tx struct {
+	tx       driver.Tx
+	recorder *recorder
+	ctx      context.Context
+	start    time.Time
+}
+
+func (t *tx) Commit() error {
+	start := time.Now()
+	err := t.tx.Commit()
+	t.recorder.record(t.ctx, "commit", start, err)
+	t.recorder.record(t.ctx, "tx", t.start, err)
+	return err
+}
+
+func (t *tx) Rollback() error {
+	start := time.Now()
+	err := t.tx.Rollback()
+	t.recorder.record(t.ctx, "rollback", start, err)
+	t.recorder.record(t.ctx, "tx", t.start, err)
+	return err
+}
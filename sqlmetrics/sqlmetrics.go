@@ -0,0 +1,239 @@
+// Package sqlmetrics wraps a `database/sql/driver` to emit query metrics via
+// a `metrics.Client` without changing any query call sites:
+//
+//   db := sql.OpenDB(sqlmetrics.WrapConnector(connector, client))
+//
+//   // Or wrap a driver and register it under a new name.
+//   sql.Register("postgres-metrics", sqlmetrics.Wrap(&pq.Driver{}, client))
+//   db, err := sql.Open("postgres-metrics", dsn)
+//
+// The following metrics are emitted, tagged with `operation` (one of `exec`,
+// `query`, `prepare`, `begin`, `commit`, `rollback`, or `tx` for the duration
+// of a whole transaction), `status` (`success` or `error`), and `query` when a
+// name was set via `WithQueryName`:
+//
+//   - `sql.latency` timing of each operation
+//   - `sql.errors` count of failed operations, with an additional `class` tag
+//
+// Use `ReportStats` to periodically emit gauges from `sql.DBStats`.
+package sqlmetrics
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/istreamlabs/go-metrics/metrics"
+)
+
+// Options contains the configuration options for a wrapped driver.
+type Options struct {
+	// Prefix for metric names, defaulting to `sql`.
+	Prefix string
+
+	// ErrorClass returns a low-cardinality class for an error, e.g. based on
+	// a driver-specific error code. Defaults to `ErrorClass`.
+	ErrorClass func(err error) string
+}
+
+// Option is a driver wrapper option.
+type Option func(*Options)
+
+// WithPrefix sets the metric name prefix.
+func WithPrefix(prefix string) Option {
+	return func(o *Options) {
+		o.Prefix = prefix
+	}
+}
+
+// WithErrorClass sets the function used to classify errors.
+func WithErrorClass(f func(err error) string) Option {
+	return func(o *Options) {
+		o.ErrorClass = f
+	}
+}
+
+func resolveOptions(options []Option) *Options {
+	o := &Options{
+		Prefix:     "sql",
+		ErrorClass: ErrorClass,
+	}
+	for _, option := range options {
+		option(o)
+	}
+	return o
+}
+
+// ErrorClass returns a class for common `database/sql` and context errors,
+// including when wrapped by the driver, or `other`.
+func ErrorClass(err error) string {
+	switch {
+	case errors.Is(err, driver.ErrBadConn):
+		return "bad_conn"
+	case errors.Is(err, sql.ErrTxDone):
+		return "tx_done"
+	case errors.Is(err, sql.ErrNoRows):
+		return "no_rows"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	}
+	return "other"
+}
+
+// queryNameKey is the context key for a query name.
+type queryNameKey struct{}
+
+// WithQueryName returns a copy of the context which tags metrics for any
+// operation using it with the query name, e.g.:
+//
+//   ctx = sqlmetrics.WithQueryName(ctx, "get_user")
+//   db.QueryRowContext(ctx, "SELECT * FROM users WHERE id = $1", id)
+//
+// Names should come from a small fixed set to avoid high tag cardinality.
+func WithQueryName(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, queryNameKey{}, name)
+}
+
+// recorder emits operation metrics.
+type recorder struct {
+	client  metrics.Client
+	options *Options
+}
+
+// record emits metrics for an operation which started at `start`. Calls
+// which the driver skipped are not recorded since `database/sql` retries them
+// another way.
+func (r *recorder) record(ctx context.Context, operation string, start time.Time, err error) {
+	if err == driver.ErrSkip {
+		return
+	}
+
+	tags := map[string]string{
+		"operation": operation,
+		"status":    "success",
+	}
+	if ctx != nil {
+		if name, ok := ctx.Value(queryNameKey{}).(string); ok && name != "" {
+			tags["query"] = name
+		}
+	}
+	if err != nil {
+		tags["status"] = "error"
+	}
+
+	tagged := r.client.WithTags(tags)
+	tagged.Timing(r.options.Prefix+".latency", time.Since(start))
+	if err != nil {
+		tagged.WithTags(map[string]string{
+			"class": r.options.ErrorClass(err),
+		}).Incr(r.options.Prefix + ".errors")
+	}
+}
+
+// Wrap returns a driver which emits metrics for all connections opened by
+// `d`.
+func Wrap(d driver.Driver, client metrics.Client, options ...Option) driver.Driver {
+	return &wrappedDriver{
+		driver:   d,
+		recorder: &recorder{client: client, options: resolveOptions(options)},
+	}
+}
+
+// WrapConnector returns a connector which emits metrics for all connections
+// opened by `c`, for use with `sql.OpenDB`.
+func WrapConnector(c driver.Connector, client metrics.Client, options ...Option) driver.Connector {
+	r := &recorder{client: client, options: resolveOptions(options)}
+	return &connector{
+		connector: c,
+		driver:    &wrappedDriver{driver: c.Driver(), recorder: r},
+		recorder:  r,
+	}
+}
+
+type wrappedDriver struct {
+	driver   driver.Driver
+	recorder *recorder
+}
+
+func (d *wrappedDriver) Open(name string) (driver.Conn, error) {
+	c, err := d.driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{conn: c, recorder: d.recorder}, nil
+}
+
+// OpenConnector implements `driver.DriverContext`.
+func (d *wrappedDriver) OpenConnector(name string) (driver.Connector, error) {
+	if dc, ok := d.driver.(driver.DriverContext); ok {
+		c, err := dc.OpenConnector(name)
+		if err != nil {
+			return nil, err
+		}
+		return &connector{connector: c, driver: d, recorder: d.recorder}, nil
+	}
+	return &connector{connector: dsnConnector{name: name, driver: d.driver}, driver: d, recorder: d.recorder}, nil
+}
+
+// dsnConnector opens connections from a name for drivers which do not
+// implement `driver.DriverContext`.
+type dsnConnector struct {
+	name   string
+	driver driver.Driver
+}
+
+func (c dsnConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.name)
+}
+
+func (c dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+type connector struct {
+	connector driver.Connector
+	driver    *wrappedDriver
+	recorder  *recorder
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	dc, err := c.connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{conn: dc, recorder: c.recorder}, nil
+}
+
+func (c *connector) Driver() driver.Driver {
+	return c.driver
+}
+
+// Close implements `io.Closer`, which `sql.DB.Close` calls on connectors, if
+// the wrapped connector does.
+func (c *connector) Close() error {
+	if cl, ok := c.connector.(io.Closer); ok {
+		return cl.Close()
+	}
+	return nil
+}
+
+// errNamedArgs is returned when a driver without context support is passed
+// named arguments.
+var errNamedArgs = errors.New("sqlmetrics: driver does not support named arguments")
+
+// namedToValues converts named values for drivers without context support.
+func namedToValues(named []driver.NamedValue) ([]driver.Value, error) {
+	args := make([]driver.Value, len(named))
+	for i, nv := range named {
+		if nv.Name != "" {
+			return nil, errNamedArgs
+		}
+		args[i] = nv.Value
+	}
+	return args, nil
+}
@@ -0,0 +1,359 @@
+package sqlmetrics_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/istreamlabs/go-metrics/metrics"
+	"github.com/istreamlabs/go-metrics/sqlmetrics"
+)
+
+var errSyntax = errors.New("syntax error")
+
+// fakeDriver is an in-process driver whose statements succeed unless the
+// query is `FAIL`.
+type fakeDriver struct{}
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) {
+	return &fakeConn{}, nil
+}
+
+type fakeConnector struct{}
+
+func (c *fakeConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return &fakeConn{}, nil
+}
+
+func (c *fakeConnector) Driver() driver.Driver {
+	return &fakeDriver{}
+}
+
+// fakeConn supports the context interfaces.
+type fakeConn struct{}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{query: query}, nil
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return &fakeTx{}, nil
+}
+
+func (c *fakeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return &fakeTx{}, nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if query == "FAIL" {
+		return nil, errSyntax
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if query == "FAIL" {
+		return nil, errSyntax
+	}
+	return &fakeRows{}, nil
+}
+
+type fakeStmt struct {
+	query string
+}
+
+func (s *fakeStmt) Close() error {
+	return nil
+}
+
+func (s *fakeStmt) NumInput() int {
+	return -1
+}
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if s.query == "FAIL" {
+		return nil, errSyntax
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	if s.query == "FAIL" {
+		return nil, errSyntax
+	}
+	return &fakeRows{}, nil
+}
+
+type fakeTx struct{}
+
+func (t *fakeTx) Commit() error {
+	return nil
+}
+
+func (t *fakeTx) Rollback() error {
+	return nil
+}
+
+// fakeRows returns a single row with a single column.
+type fakeRows struct {
+	done bool
+}
+
+func (r *fakeRows) Columns() []string {
+	return []string{"n"}
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = int64(1)
+	return nil
+}
+
+// legacyDriver only supports the non-context interfaces.
+type legacyDriver struct{}
+
+func (d *legacyDriver) Open(name string) (driver.Conn, error) {
+	return &legacyConn{}, nil
+}
+
+type legacyConn struct{}
+
+func (c *legacyConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{query: query}, nil
+}
+
+func (c *legacyConn) Close() error {
+	return nil
+}
+
+func (c *legacyConn) Begin() (driver.Tx, error) {
+	return &fakeTx{}, nil
+}
+
+// legacyRecorder is used by the registered legacy driver.
+var legacyRecorder = metrics.NewRecorderClient()
+
+func init() {
+	sql.Register("sqlmetrics-legacy", sqlmetrics.Wrap(&legacyDriver{}, legacyRecorder))
+}
+
+func TestConnector(t *testing.T) {
+	recorder := metrics.NewRecorderClient().WithTest(t)
+	db := sql.OpenDB(sqlmetrics.WrapConnector(&fakeConnector{}, recorder))
+	defer db.Close()
+
+	ctx := sqlmetrics.WithQueryName(context.Background(), "insert_user")
+	if _, err := db.ExecContext(ctx, "INSERT INTO users VALUES (?)", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	var n int
+	if err := db.QueryRow("SELECT 1").Scan(&n); err != nil || n != 1 {
+		t.Fatalf("Expected 1 but got %d, %v", n, err)
+	}
+
+	if _, err := db.Exec("FAIL"); err != errSyntax {
+		t.Fatalf("Expected syntax error but got %v", err)
+	}
+
+	recorder.Expect("sql.latency").Tags(map[string]string{"operation": "exec", "status": "success", "query": "insert_user"})
+	recorder.Expect("sql.latency").Tags(map[string]string{"operation": "query", "status": "success"})
+	recorder.Expect("sql.latency").Tags(map[string]string{"operation": "exec", "status": "error"})
+	recorder.Expect("sql.errors").Tags(map[string]string{"operation": "exec", "status": "error", "class": "other"}).Value(1)
+}
+
+// closingConnector tracks whether it was closed and returns connections which
+// report themselves as invalid.
+type closingConnector struct {
+	fakeConnector
+	closed bool
+}
+
+func (c *closingConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return &invalidConn{}, nil
+}
+
+func (c *closingConnector) Close() error {
+	c.closed = true
+	return nil
+}
+
+type invalidConn struct {
+	fakeConn
+}
+
+func (c *invalidConn) IsValid() bool {
+	return false
+}
+
+func TestConnectorForwarding(t *testing.T) {
+	recorder := metrics.NewRecorderClient().WithTest(t)
+	connector := &closingConnector{}
+	db := sql.OpenDB(sqlmetrics.WrapConnector(connector, recorder))
+
+	if _, err := db.Exec("INSERT"); err != nil {
+		t.Fatal(err)
+	}
+	if open := db.Stats().OpenConnections; open != 0 {
+		t.Fatalf("Expected invalid connection to be discarded but %d are open", open)
+	}
+
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !connector.closed {
+		t.Fatal("Expected wrapped connector to be closed")
+	}
+}
+
+func TestTransactions(t *testing.T) {
+	recorder := metrics.NewRecorderClient().WithTest(t)
+	db := sql.OpenDB(sqlmetrics.WrapConnector(&fakeConnector{}, recorder))
+	defer db.Close()
+
+	ctx := sqlmetrics.WithQueryName(context.Background(), "transfer")
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Exec("UPDATE accounts"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err = db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, operation := range []string{"begin", "commit", "tx"} {
+		recorder.Expect("sql.latency").Tags(map[string]string{"operation": operation, "status": "success", "query": "transfer"})
+	}
+	recorder.Expect("sql.latency").Tags(map[string]string{"operation": "exec", "status": "success"})
+	recorder.Expect("sql.latency").Tags(map[string]string{"operation": "rollback", "status": "success"})
+	recorder.Expect("sql.latency").MinTimes(2).Tag("operation", "tx")
+}
+
+func TestLegacyDriver(t *testing.T) {
+	legacyRecorder.Reset()
+	db, err := sql.Open("sqlmetrics-legacy", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec("INSERT", 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Query("FAIL"); err != errSyntax {
+		t.Fatalf("Expected syntax error but got %v", err)
+	}
+
+	recorder := legacyRecorder.WithTest(t)
+	recorder.Expect("sql.latency").MinTimes(2).Tag("operation", "prepare")
+	recorder.Expect("sql.latency").Tags(map[string]string{"operation": "exec", "status": "success"})
+	recorder.Expect("sql.errors").Tags(map[string]string{"operation": "query", "status": "error", "class": "other"})
+}
+
+func TestErrorClass(t *testing.T) {
+	for err, expected := range map[error]string{
+		driver.ErrBadConn:        "bad_conn",
+		sql.ErrTxDone:            "tx_done",
+		sql.ErrNoRows:            "no_rows",
+		context.Canceled:         "canceled",
+		context.DeadlineExceeded: "timeout",
+		errSyntax:                "other",
+
+		// Drivers commonly wrap these errors.
+		fmt.Errorf("read tcp: %w", context.Canceled):     "canceled",
+		fmt.Errorf("conn closed: %w", driver.ErrBadConn): "bad_conn",
+	} {
+		if class := sqlmetrics.ErrorClass(err); class != expected {
+			t.Errorf("Expected %v to be %s but got %s", err, expected, class)
+		}
+	}
+
+	recorder := metrics.NewRecorderClient().WithTest(t)
+	db := sql.OpenDB(sqlmetrics.WrapConnector(&fakeConnector{}, recorder,
+		sqlmetrics.WithPrefix("db"),
+		sqlmetrics.WithErrorClass(func(err error) string { return "syntax" })))
+	defer db.Close()
+
+	db.Exec("FAIL")
+	recorder.Expect("db.errors").Tag("class", "syntax")
+}
+
+func TestReportStats(t *testing.T) {
+	recorder := metrics.NewRecorderClient().WithTest(t)
+	db := sql.OpenDB(sqlmetrics.WrapConnector(&fakeConnector{}, recorder))
+	defer db.Close()
+	db.SetMaxOpenConns(5)
+	if err := db.Ping(); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := make(chan metrics.Call, 100)
+	unsubscribe := recorder.SubscribeChan(calls, metrics.Match().Contains("sql.connections.max_lifetime_closed"))
+	defer unsubscribe()
+
+	stop := sqlmetrics.ReportStats(db, recorder, time.Millisecond)
+	for i := 0; i < 2; i++ {
+		select {
+		case <-calls:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for stats")
+		}
+	}
+	stop()
+	stop()
+
+	recorder.Expect("sql.connections.max_open").Value(5)
+	recorder.Expect("sql.connections.open").Value(1)
+	recorder.Expect("sql.connections.idle").Value(1)
+	recorder.Expect("sql.connections.in_use").Value(0)
+
+	// Stop waits for the reporting goroutine to return, so once the stats it
+	// already sent are drained nothing else arrives, even over several
+	// intervals.
+	for len(calls) > 0 {
+		<-calls
+	}
+	select {
+	case call := <-calls:
+		t.Fatalf("Expected no stats after stopping but got %v", call)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func TestReportStatsDefaultInterval(t *testing.T) {
+	recorder := metrics.NewRecorderClient().WithTest(t)
+	db := sql.OpenDB(sqlmetrics.WrapConnector(&fakeConnector{}, recorder))
+	defer db.Close()
+
+	// Stats are emitted immediately, then on the default interval.
+	stop := sqlmetrics.ReportStats(db, recorder, 0)
+	stop()
+
+	recorder.Expect("sql.connections.open").Value(0)
+}
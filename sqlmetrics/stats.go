@@ -0,0 +1,77 @@
+package sqlmetrics
+
+import (
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/istreamlabs/go-metrics/metrics"
+)
+
+// DefaultStatsInterval is the interval used by `ReportStats` when the given
+// interval is zero or negative.
+const DefaultStatsInterval = 10 * time.Second
+
+// ReportStats emits gauges from `db.Stats()` every `interval` until the
+// returned stop function is called:
+//
+//   stop := sqlmetrics.ReportStats(db, client.WithTags(map[string]string{
+//     "db": "users",
+//   }), 10*time.Second)
+//   defer stop()
+//
+// The gauges are named `sql.connections.max_open`, `sql.connections.open`,
+// `sql.connections.in_use`, `sql.connections.idle`, `sql.connections.wait_count`,
+// `sql.connections.wait_duration` (in seconds),
+// `sql.connections.max_idle_closed`, and `sql.connections.max_lifetime_closed`.
+// The counters from `sql.DBStats` are reported as gauges of their running
+// totals. Use `WithPrefix` to change the `sql` prefix.
+func ReportStats(db *sql.DB, client metrics.Client, interval time.Duration, options ...Option) (stop func()) {
+	o := resolveOptions(options)
+	if interval <= 0 {
+		interval = DefaultStatsInterval
+	}
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			emitStats(db.Stats(), client, o.Prefix)
+			select {
+			case <-ticker.C:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			<-stopped
+		})
+	}
+}
+
+// EmitStats emits gauges from a single `sql.DBStats` snapshot, see
+// `ReportStats`.
+func EmitStats(stats sql.DBStats, client metrics.Client, options ...Option) {
+	emitStats(stats, client, resolveOptions(options).Prefix)
+}
+
+func emitStats(stats sql.DBStats, client metrics.Client, prefix string) {
+	prefix += ".connections."
+	client.Gauge(prefix+"max_open", float64(stats.MaxOpenConnections))
+	client.Gauge(prefix+"open", float64(stats.OpenConnections))
+	client.Gauge(prefix+"in_use", float64(stats.InUse))
+	client.Gauge(prefix+"idle", float64(stats.Idle))
+	client.Gauge(prefix+"wait_count", float64(stats.WaitCount))
+	client.Gauge(prefix+"wait_duration", stats.WaitDuration.Seconds())
+	client.Gauge(prefix+"max_idle_closed", float64(stats.MaxIdleClosed))
+	client.Gauge(prefix+"max_lifetime_closed", float64(stats.MaxLifetimeClosed))
+}